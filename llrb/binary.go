@@ -0,0 +1,81 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	errCorrupt  = errors.New("llrb: corrupt binary encoding")
+	errUnsorted = errors.New("llrb: decoded items are not in ascending order")
+)
+
+// MarshalBinary encodes the items of the tree in ascending order, using
+// encodeItem to encode each item. The encoding is the number of items,
+// followed by the length and bytes of each encoded item.
+func (t *LLRB) MarshalBinary(encodeItem func(Item) ([]byte, error)) ([]byte, error) {
+	buf := binary.AppendUvarint(nil, uint64(t.count))
+	var err error
+	t.Ascend(func(i Item) bool {
+		var b []byte
+		if b, err = encodeItem(i); err != nil {
+			return false
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(b)))
+		buf = append(buf, b...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary replaces the contents of the tree with the items encoded in
+// data by MarshalBinary, using decodeItem to decode each item. The tree is
+// left unchanged if an error is returned.
+func (t *LLRB) UnmarshalBinary(data []byte, decodeItem func([]byte) (Item, error)) error {
+	count, k := binary.Uvarint(data)
+	if k <= 0 || count > uint64(len(data)) {
+		return errCorrupt
+	}
+	data = data[k:]
+	items := make([]Item, 0, count)
+	for i := uint64(0); i < count; i++ {
+		n, k := binary.Uvarint(data)
+		if k <= 0 || n > uint64(len(data)-k) {
+			return errCorrupt
+		}
+		item, err := decodeItem(data[k : k+int(n)])
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+		data = data[k+int(n):]
+	}
+	if len(data) != 0 {
+		return errCorrupt
+	}
+	return t.load(items)
+}
+
+// load replaces the contents of the tree with items, which must be in
+// ascending order. It returns ErrNilItem or errUnsorted, and leaves the tree
+// unchanged, if they are not.
+func (t *LLRB) load(items []Item) error {
+	for i, item := range items {
+		if item == nil {
+			return ErrNilItem
+		}
+		if i > 0 && less(t.comp, item, items[i-1]) {
+			return errUnsorted
+		}
+	}
+	t.Clear()
+	t.loadSorted(items)
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func encodeString(i Item) ([]byte, error) { return []byte(i.(String)), nil }
+
+func decodeString(b []byte) (Item, error) { return String(b), nil }
+
+func TestMarshalBinary(t *testing.T) {
+	tree := New(NaturalSortLessString)
+	for _, i := range rand.Perm(500) {
+		tree.ReplaceOrInsert(String(strconv.Itoa(i)))
+	}
+	tree.InsertNoReplace(String("42"))
+	data, err := tree.MarshalBinary(encodeString)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	decoded := New(NaturalSortLessString)
+	decoded.ReplaceOrInsert(String("stale"))
+	if err := decoded.UnmarshalBinary(data, decodeString); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.Len() != tree.Len() {
+		t.Errorf("expected len %d, got %d", tree.Len(), decoded.Len())
+	}
+	if !reflect.DeepEqual(collectAll(decoded), collectAll(tree)) {
+		t.Errorf("decoded tree differs from the original")
+	}
+	checkSizes(t, decoded.Root())
+
+	if err := decoded.UnmarshalBinary(data[:len(data)-1], decodeString); err == nil {
+		t.Errorf("expected an error for truncated data")
+	}
+	if decoded.Len() != tree.Len() {
+		t.Errorf("failed unmarshal changed the tree")
+	}
+
+	// The tree is checked before it is replaced.
+	decodeNil := func(b []byte) (Item, error) {
+		if string(b) == "42" {
+			return nil, nil
+		}
+		return String(b), nil
+	}
+	if err := decoded.UnmarshalBinary(data, decodeNil); err != ErrNilItem {
+		t.Errorf("expected ErrNilItem, got %v", err)
+	}
+	decodeReversed := func(b []byte) (Item, error) {
+		if string(b) == "0" {
+			return String("~"), nil
+		}
+		return String(b), nil
+	}
+	if err := decoded.UnmarshalBinary(data, decodeReversed); err != errUnsorted {
+		t.Errorf("expected errUnsorted, got %v", err)
+	}
+	if !reflect.DeepEqual(collectAll(decoded), collectAll(tree)) {
+		t.Errorf("failed unmarshal changed the tree")
+	}
+	checkInvariants(t, decoded)
+}
+
+func TestUnmarshalBinaryPooled(t *testing.T) {
+	tree := NewPooled(NaturalSortLessString)
+	for i := 0; i < 100; i++ {
+		tree.ReplaceOrInsert(String(strconv.Itoa(i)))
+	}
+	data, err := tree.MarshalBinary(encodeString)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	nodes := map[*Node]bool{}
+	var walk func(h *Node, visit func(*Node))
+	walk = func(h *Node, visit func(*Node)) {
+		if h != nil {
+			visit(h)
+			walk(h.Left, visit)
+			walk(h.Right, visit)
+		}
+	}
+	walk(tree.Root(), func(h *Node) { nodes[h] = true })
+	if err := tree.UnmarshalBinary(data[:1], decodeString); err == nil {
+		t.Fatalf("expected an error for truncated data")
+	}
+	if err := tree.UnmarshalBinary(data, decodeString); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	walk(tree.Root(), func(h *Node) {
+		if !nodes[h] {
+			t.Fatalf("expected the old nodes to be reused, got a new node for %v", h.Item)
+		}
+	})
+	checkInvariants(t, tree)
+}
@@ -0,0 +1,51 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+// iterBufferSize is the capacity of the channels returned by the Iter methods.
+const iterBufferSize = 16
+
+// IterAscend returns a channel that receives every element in the tree, in
+// ascending order, and is closed once the traversal ends. The traversal runs
+// in its own goroutine. Closing done abandons it; the goroutine then exits
+// without sending further elements and closes the channel. A nil done is
+// allowed if the channel will always be drained. The tree must not be
+// modified until the channel has been closed.
+func (t *LLRB) IterAscend(done <-chan struct{}) <-chan Item {
+	return t.iter(done, func(iterator ItemIterator) {
+		t.ascend(t.root, iterator)
+	})
+}
+
+// IterDescend is like IterAscend, but the elements are sent in descending order.
+func (t *LLRB) IterDescend(done <-chan struct{}) <-chan Item {
+	return t.iter(done, func(iterator ItemIterator) {
+		t.descend(t.root, iterator)
+	})
+}
+
+// IterRange is like IterAscend, but only the elements greater or equal to
+// lo and less than hi are sent.
+func (t *LLRB) IterRange(lo, hi Item, done <-chan struct{}) <-chan Item {
+	return t.iter(done, func(iterator ItemIterator) {
+		t.ascendRange(t.root, lo, hi, iterator)
+	})
+}
+
+func (t *LLRB) iter(done <-chan struct{}, traverse func(ItemIterator)) <-chan Item {
+	c := make(chan Item, iterBufferSize)
+	go func() {
+		defer close(c)
+		traverse(func(i Item) bool {
+			select {
+			case c <- i:
+				return true
+			case <-done:
+				return false
+			}
+		})
+	}()
+	return c
+}
@@ -0,0 +1,67 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestIterAscendDescend(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 100; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	j := 0
+	for item := range tree.IterAscend(nil) {
+		if item != Int(j) {
+			t.Fatalf("expected %d, got %v", j, item)
+		}
+		j++
+	}
+	if j != 100 {
+		t.Errorf("expected 100 items, got %d", j)
+	}
+	for item := range tree.IterDescend(nil) {
+		j--
+		if item != Int(j) {
+			t.Fatalf("expected %d, got %v", j, item)
+		}
+	}
+	j = 10
+	for item := range tree.IterRange(Int(10), Int(20), nil) {
+		if item != Int(j) {
+			t.Fatalf("expected %d, got %v", j, item)
+		}
+		j++
+	}
+	if j != 20 {
+		t.Errorf("expected range to end at 20, got %d", j)
+	}
+}
+
+func TestIterAbandon(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 10000; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	before := runtime.NumGoroutine()
+	for k := 0; k < 10; k++ {
+		done := make(chan struct{})
+		c := tree.IterAscend(done)
+		for i := 0; i < 5; i++ {
+			<-c
+		}
+		close(done)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("leaked %d goroutines", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
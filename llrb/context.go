@@ -0,0 +1,62 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import "context"
+
+// contextCheckInterval is the number of items visited between checks of the
+// context in the context-aware traversals.
+const contextCheckInterval = 256
+
+// AscendContext is like AscendGreaterOrEqual, but stops early and returns
+// ctx.Err() if ctx is cancelled during the traversal. The context is checked
+// every few hundred items, so a cancelled traversal may visit a few more items
+// before it stops.
+func (t *LLRB) AscendContext(ctx context.Context, pivot Item, iterator ItemIterator) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := withContext(ctx, iterator)
+	t.AscendGreaterOrEqual(pivot, f)
+	return *err
+}
+
+// AscendRangeContext is like AscendRange, but stops early and returns
+// ctx.Err() if ctx is cancelled during the traversal.
+func (t *LLRB) AscendRangeContext(ctx context.Context, greaterOrEqual, lessThan Item, iterator ItemIterator) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := withContext(ctx, iterator)
+	t.AscendRange(greaterOrEqual, lessThan, f)
+	return *err
+}
+
+// DescendContext is like DescendLessOrEqual, but stops early and returns
+// ctx.Err() if ctx is cancelled during the traversal.
+func (t *LLRB) DescendContext(ctx context.Context, pivot Item, iterator ItemIterator) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := withContext(ctx, iterator)
+	t.DescendLessOrEqual(pivot, f)
+	return *err
+}
+
+// withContext wraps iterator so that it stops when ctx is cancelled, in which
+// case the context's error is stored in the returned error.
+func withContext(ctx context.Context, iterator ItemIterator) (ItemIterator, *error) {
+	var err error
+	n := 0
+	return func(i Item) bool {
+		n++
+		if n%contextCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		return iterator(i)
+	}, &err
+}
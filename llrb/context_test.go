@@ -0,0 +1,108 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAscendContext(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 10000
+	for i := 0; i < n; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	k := 0
+	if err := tree.AscendContext(context.Background(), Int(100), func(Item) bool {
+		k++
+		return true
+	}); err != nil || k != n-100 {
+		t.Errorf("expected %d items and no error, got %d and %v", n-100, k, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k = 0
+	err := tree.AscendContext(ctx, Inf(-1), func(Item) bool {
+		k++
+		if k == 1000 {
+			cancel()
+		}
+		return true
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if k >= 1000+contextCheckInterval+1 {
+		t.Errorf("visited %d items after cancellation", k-1000)
+	}
+
+	err = tree.AscendRangeContext(ctx, Int(0), Int(10), func(i Item) bool {
+		t.Errorf("visited %v with a cancelled context", i)
+		return true
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestDescendContext(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 10000
+	for i := 0; i < n; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	k := 0
+	if err := tree.DescendContext(context.Background(), Int(99), func(i Item) bool {
+		if i != Int(99-k) {
+			t.Fatalf("expected %d, got %v", 99-k, i)
+		}
+		k++
+		return true
+	}); err != nil || k != 100 {
+		t.Errorf("expected 100 items and no error, got %d and %v", k, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k = 0
+	err := tree.DescendContext(ctx, Inf(1), func(Item) bool {
+		k++
+		if k == 1000 {
+			cancel()
+		}
+		return true
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if k >= 1000+contextCheckInterval+1 {
+		t.Errorf("visited %d items after cancellation", k-1000)
+	}
+}
+
+func benchmarkContextTree(b *testing.B) *LLRB {
+	b.StopTimer()
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 100000; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	b.StartTimer()
+	return tree
+}
+
+func BenchmarkAscendPlain(b *testing.B) {
+	tree := benchmarkContextTree(b)
+	for i := 0; i < b.N; i++ {
+		tree.AscendGreaterOrEqual(Inf(-1), func(Item) bool { return true })
+	}
+}
+
+func BenchmarkAscendContext(b *testing.B) {
+	tree := benchmarkContextTree(b)
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		tree.AscendContext(ctx, Inf(-1), func(Item) bool { return true })
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCursor is matched, with errors.Is, by the errors SeekCursor
+// returns for a cursor that was not made by Cursor, or whose key cannot be
+// decoded.
+var ErrInvalidCursor = errors.New("llrb: invalid cursor")
+
+// CursorError is the error returned by SeekCursor for a cursor that cannot be
+// decoded. It tells where decoding failed and why.
+type CursorError struct {
+	Offset int    // Offset in the cursor of the part that cannot be decoded
+	Reason string // What is wrong with it
+	Err    error  // The error returned by decodeKey, if any
+}
+
+func (e *CursorError) Error() string {
+	msg := fmt.Sprintf("llrb: invalid cursor at offset %d: %s", e.Offset, e.Reason)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is reports whether target is ErrInvalidCursor.
+func (e *CursorError) Is(target error) bool { return target == ErrInvalidCursor }
+
+// Unwrap returns the error returned by decodeKey, if any.
+func (e *CursorError) Unwrap() error { return e.Err }
+
+// Kinds of cursors, by how they tell apart items of the same order.
+const (
+	cursorOrdinal = iota // Index among the items of the same order
+	cursorSeq            // Sequence number, in stable trees
+)
+
+// Cursor returns an encoding of the iterator's position, from which
+// SeekCursor can resume iterating, possibly over another Iterator on the same
+// tree. The key of the current item is encoded with encodeKey. Cursor panics
+// if the iterator is not positioned at an item.
+//
+// Among items of the same order, a stable tree (see NewStable) resumes exactly
+// after the current item. Other trees resume by the index of the item among
+// those of the same order, so inserting or deleting an item of the same order
+// as the current one may cause an item to be skipped or repeated.
+func (it *Iterator) Cursor(encodeKey func(Item) ([]byte, error)) ([]byte, error) {
+	it.check()
+	if len(it.stack) == 0 {
+		panic("llrb: iterator not positioned at an item")
+	}
+	h := it.stack[len(it.stack)-1]
+	key, err := encodeKey(h.Item)
+	if err != nil {
+		return nil, err
+	}
+	var buf []byte
+	if it.t.stable && h.seq != 0 {
+		buf = append(buf, cursorSeq)
+		buf = binary.AppendUvarint(buf, h.seq)
+	} else {
+		buf = append(buf, cursorOrdinal)
+		buf = binary.AppendUvarint(buf, uint64(it.index()-it.t.Rank(h.Item)))
+	}
+	return append(buf, key...), nil
+}
+
+// SeekCursor moves the iterator to the first item after the position encoded
+// in c by Cursor, decoding the key with decodeKey. Like Seek, it returns false
+// if there is no such item, and leaves the iterator after the largest item.
+// If c cannot be decoded, the error is a *CursorError, which matches
+// ErrInvalidCursor, and the iterator is left unchanged.
+func (it *Iterator) SeekCursor(c []byte, decodeKey func([]byte) (Item, error)) (bool, error) {
+	if len(c) == 0 {
+		return false, &CursorError{Offset: 0, Reason: "empty cursor"}
+	}
+	if c[0] != cursorOrdinal && c[0] != cursorSeq {
+		return false, &CursorError{Offset: 0, Reason: fmt.Sprintf("unknown kind %d", c[0])}
+	}
+	n, k := binary.Uvarint(c[1:])
+	if k <= 0 {
+		return false, &CursorError{Offset: 1, Reason: "truncated or overlong position"}
+	}
+	key, err := decodeKey(c[1+k:])
+	if err != nil {
+		return false, &CursorError{Offset: 1 + k, Reason: "key cannot be decoded", Err: err}
+	}
+	if key == nil {
+		return false, &CursorError{Offset: 1 + k, Reason: "key decoded as nil"}
+	}
+
+	it.mods = it.t.mods
+	it.stack = it.stack[:0]
+	it.end = 1
+	if c[0] == cursorSeq {
+		found := 0
+		for h := it.t.root; h != nil; {
+			it.stack = append(it.stack, h)
+			if r := it.t.compare(key, h.Item); r < 0 || r == 0 && h.seq > n {
+				found = len(it.stack)
+				h = h.Left
+			} else {
+				h = h.Right
+			}
+		}
+		it.stack = it.stack[:found]
+		return found > 0, nil
+	}
+
+	// The position is capped at the end of the items of the same order as
+	// key, in case some of them have been deleted.
+	pos := it.t.Rank(key)
+	if end := it.t.rankAfter(key); n < uint64(end-pos) {
+		pos += int(n) + 1
+	} else {
+		pos = end
+	}
+	for h := it.t.root; h != nil; {
+		it.stack = append(it.stack, h)
+		l := size(h.Left)
+		switch {
+		case pos < l:
+			h = h.Left
+		case pos > l:
+			pos -= l + 1
+			h = h.Right
+		default:
+			return true, nil
+		}
+	}
+	it.stack = it.stack[:0]
+	return false, nil
+}
+
+// index returns the number of items before the iterator's current item.
+func (it *Iterator) index() int {
+	i := size(it.stack[len(it.stack)-1].Left)
+	for k := 1; k < len(it.stack); k++ {
+		if h := it.stack[k-1]; h.Right == it.stack[k] {
+			i += size(h.Left) + 1
+		}
+	}
+	return i
+}
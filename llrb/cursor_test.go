@@ -0,0 +1,170 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func encodeTaggedKey(i Item) ([]byte, error) {
+	return binary.AppendVarint(nil, int64(i.(tagged).key)), nil
+}
+
+func decodeTaggedKey(b []byte) (Item, error) {
+	k, n := binary.Varint(b)
+	if n <= 0 || n != len(b) {
+		return nil, errors.New("bad key")
+	}
+	return tagged{Int(k), 0}, nil
+}
+
+// paginate reads the tree in pages of size items, resuming each page from the
+// cursor of the previous one on a new Iterator, and calls between after each.
+func paginate(t *testing.T, tree *LLRB, size int, between func()) []Item {
+	var items []Item
+	var c []byte
+	for {
+		it := tree.NewIterator()
+		ok := it.First()
+		if c != nil {
+			var err error
+			if ok, err = it.SeekCursor(c, decodeTaggedKey); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for n := 0; ok && n < size; n++ {
+			items = append(items, it.Item())
+			var err error
+			if c, err = it.Cursor(encodeTaggedKey); err != nil {
+				t.Fatal(err)
+			}
+			if n < size-1 {
+				ok = it.Next()
+			}
+		}
+		if !ok {
+			return items
+		}
+		between()
+	}
+}
+
+func TestCursorDuplicates(t *testing.T) {
+	for _, tree := range []*LLRB{New(lessTagged), NewStable(lessTagged)} {
+		var want []Item
+		for k := 0; k < 20; k++ {
+			for d := 0; d < 5; d++ {
+				want = append(want, tagged{Int(2 * k), d})
+				tree.InsertNoReplace(tagged{Int(2 * k), d})
+			}
+		}
+		// Unrelated items are inserted and deleted between pages.
+		odd := 1
+		got := paginate(t, tree, 7, func() {
+			tree.ReplaceOrInsert(tagged{Int(odd), -1})
+			if odd > 10 {
+				tree.Delete(tagged{Int(odd - 10), -1})
+			}
+			odd += 2
+		})
+		var even []Item
+		for _, i := range got {
+			if i.(tagged).key%2 == 0 {
+				even = append(even, i)
+			}
+		}
+		if len(even) != len(want) {
+			t.Fatalf("stable=%v: got %d items, expected %d", tree.stable, len(even), len(want))
+		}
+		for k := range want {
+			if even[k] != want[k] {
+				t.Fatalf("stable=%v: item %d: got %v, expected %v", tree.stable, k, even[k], want[k])
+			}
+		}
+	}
+}
+
+func TestCursorStableDelete(t *testing.T) {
+	tree := NewStable(lessTagged)
+	for d := 0; d < 5; d++ {
+		tree.InsertNoReplace(tagged{Int(1), d})
+	}
+	it := tree.NewIterator()
+	it.First()
+	it.Next()
+	c, _ := it.Cursor(encodeTaggedKey)
+	// Deleting the oldest item shifts the index of the current one, but not
+	// its sequence number.
+	tree.Delete(tagged{Int(1), -1})
+	it = tree.NewIterator()
+	if ok, err := it.SeekCursor(c, decodeTaggedKey); !ok || err != nil || it.Item() != (tagged{Int(1), 2}) {
+		t.Fatalf("resumed at %v, %v, %v; expected tag 2", it.Item(), ok, err)
+	}
+	tree.Delete(tagged{Int(1), -1})
+	tree.Delete(tagged{Int(1), -1})
+	tree.Delete(tagged{Int(1), -1})
+	it = tree.NewIterator()
+	if ok, err := it.SeekCursor(c, decodeTaggedKey); !ok || err != nil || it.Item() != (tagged{Int(1), 4}) {
+		t.Fatalf("resumed at %v, %v, %v; expected tag 4", it.Item(), ok, err)
+	}
+}
+
+func TestCursorDeletedKey(t *testing.T) {
+	tree := New(lessTagged)
+	for k := 0; k < 5; k++ {
+		for d := 0; d < 3; d++ {
+			tree.InsertNoReplace(tagged{Int(k), d})
+		}
+	}
+	it := tree.NewIterator()
+	it.Seek(tagged{Int(2), 0})
+	it.Next()
+	c, _ := it.Cursor(encodeTaggedKey)
+	for tree.Delete(tagged{Int(2), 0}) != nil {
+	}
+	it = tree.NewIterator()
+	if ok, err := it.SeekCursor(c, decodeTaggedKey); !ok || err != nil || it.Item().(tagged).key != 3 {
+		t.Fatalf("resumed at %v, %v, %v; expected key 3", it.Item(), ok, err)
+	}
+	tree.DeleteMax()
+	it.Last()
+	c, _ = it.Cursor(encodeTaggedKey)
+	if ok, err := it.SeekCursor(c, decodeTaggedKey); ok || err != nil {
+		t.Fatalf("expected the end of the tree, got %v, %v", ok, err)
+	}
+}
+
+func TestCursorInvalid(t *testing.T) {
+	tree := New(lessTagged)
+	tree.ReplaceOrInsert(tagged{Int(1), 0})
+	it := tree.NewIterator()
+	it.First()
+	good, _ := it.Cursor(encodeTaggedKey)
+	for _, test := range []struct {
+		c      []byte
+		offset int
+	}{
+		{nil, 0},
+		{[]byte{7}, 0},
+		{[]byte{cursorOrdinal}, 1},
+		{[]byte{cursorOrdinal, 0x80}, 1},
+		{good[:2], 2},
+		{append(good, 1), 2},
+	} {
+		_, err := it.SeekCursor(test.c, decodeTaggedKey)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %v: expected ErrInvalidCursor, got %v", test.c, err)
+		}
+		var cerr *CursorError
+		if !errors.As(err, &cerr) || cerr.Offset != test.offset {
+			t.Errorf("cursor %v: expected a CursorError at offset %d, got %v", test.c, test.offset, err)
+		}
+	}
+	if it.Item() != (tagged{Int(1), 0}) {
+		t.Errorf("invalid cursors moved the iterator")
+	}
+}
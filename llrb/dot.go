@@ -0,0 +1,43 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// WriteDOT writes the structure of the tree to w in the Graphviz DOT
+// language. Each node is labelled with its item, formatted by fmt, and each
+// link is drawn in its color.
+func (t *LLRB) WriteDOT(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph llrb {\n")
+	id := 0
+	var write func(h *Node) int
+	write = func(h *Node) int {
+		n := id
+		id++
+		fmt.Fprintf(&buf, "\tn%d [label=%q];\n", n, fmt.Sprint(h.Item))
+		for _, c := range []*Node{h.Left, h.Right} {
+			if c == nil {
+				continue
+			}
+			color := "black"
+			if isRed(c) {
+				color = "red"
+			}
+			fmt.Fprintf(&buf, "\tn%d -> n%d [color=%s];\n", n, write(c), color)
+		}
+		return n
+	}
+	if t.root != nil {
+		write(t.root)
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
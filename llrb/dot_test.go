@@ -0,0 +1,54 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 100
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	red := 0
+	tree.WalkNodes(PreOrder, func(h *Node, depth int) bool {
+		if isRed(h) {
+			red++
+		}
+		return true
+	})
+
+	var buf bytes.Buffer
+	if err := tree.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "digraph llrb {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("malformed graph:\n%s", out)
+	}
+	if c := strings.Count(out, "[label="); c != n {
+		t.Errorf("expected %d nodes, got %d", n, c)
+	}
+	if c := strings.Count(out, "[color=red]"); c != red {
+		t.Errorf("expected %d red links, got %d", red, c)
+	}
+	if c := strings.Count(out, "[color=black]"); c != n-1-red {
+		t.Errorf("expected %d black links, got %d", n-1-red, c)
+	}
+	if !strings.Contains(out, `[label="42"]`) {
+		t.Errorf("expected a node labelled 42")
+	}
+
+	buf.Reset()
+	New(NaturalSortLessInt).WriteDOT(&buf)
+	if buf.String() != "digraph llrb {\n}\n" {
+		t.Errorf("unexpected graph for an empty tree: %q", buf.String())
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+)
+
+// gobTree is the serializable view of an LLRB.
+type gobTree struct {
+	Count int
+	Items []Item // In ascending order
+}
+
+// GobEncode implements gob.GobEncoder. The concrete types of the items must
+// be registered with gob.Register.
+func (t *LLRB) GobEncode() ([]byte, error) {
+	v := gobTree{Count: t.count, Items: t.ToSlice()}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder. Since the comparison function cannot
+// be encoded, the tree must be allocated with New before decoding into it.
+// Its contents are then replaced by the decoded items.
+func (t *LLRB) GobDecode(data []byte) error {
+	if t.comp == nil {
+		return errors.New("llrb: GobDecode into a tree without a comparison function")
+	}
+	var v gobTree
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return err
+	}
+	if v.Count != len(v.Items) {
+		return errors.New("llrb: corrupt gob encoding")
+	}
+	return t.load(v.Items)
+}
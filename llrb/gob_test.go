@@ -0,0 +1,37 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/rand"
+	"testing"
+)
+
+func TestGob(t *testing.T) {
+	gob.Register(Int(0))
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(tree); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded := New(NaturalSortLessInt)
+	if err := gob.NewDecoder(&buf).Decode(decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Len() != n {
+		t.Errorf("expected len %d, got %d", n, decoded.Len())
+	}
+	for i := 0; i < n; i++ {
+		if decoded.Get(Int(i)) != Int(i) {
+			t.Fatalf("decoded tree is missing %d", i)
+		}
+	}
+}
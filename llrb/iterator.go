@@ -1,12 +1,37 @@
 package llrb
 
+// ItemIterator is called once for each item visited by a traversal.
+// Returning false stops the traversal. The iterator must not modify the
+// tree; doing so panics.
 type ItemIterator func(i Item) bool
 
-//func (t *Tree) Ascend(iterator ItemIterator) {
-//	t.AscendGreaterOrEqual(Inf(-1), iterator)
-//}
+// Ascend will call iterator once for each element in the tree, in ascending
+// order. It will stop whenever the iterator returns false. Unlike
+// AscendGreaterOrEqual, it takes no pivot and compares no items.
+func (t *LLRB) Ascend(iterator ItemIterator) {
+	defer t.walk()()
+	t.ascend(t.root, iterator)
+}
+
+func (t *LLRB) ascend(h *Node, iterator ItemIterator) bool {
+	if h == nil {
+		return true
+	}
+	if !t.ascend(h.Left, iterator) {
+		return false
+	}
+	if !iterator(h.Item) {
+		return false
+	}
+	return t.ascend(h.Right, iterator)
+}
 
+// AscendRange will call iterator once for each element greater or equal to
+// greaterOrEqual and less than lessThan, in ascending order. Subtrees that
+// cannot hold such elements are skipped. It will stop whenever the iterator
+// returns false.
 func (t *LLRB) AscendRange(greaterOrEqual, lessThan Item, iterator ItemIterator) {
+	defer t.walk()()
 	t.ascendRange(t.root, greaterOrEqual, lessThan, iterator)
 }
 
@@ -30,9 +55,38 @@ func (t *LLRB) ascendRange(h *Node, inf, sup Item, iterator ItemIterator) bool {
 	return t.ascendRange(h.Right, inf, sup, iterator)
 }
 
+// AscendEqual will call iterator once for each element that has the same
+// order as key, in the order they are stored. It will stop whenever the
+// iterator returns false.
+func (t *LLRB) AscendEqual(key Item, iterator ItemIterator) {
+	defer t.walk()()
+	t.ascendEqual(t.root, key, iterator)
+}
+
+func (t *LLRB) ascendEqual(h *Node, key Item, iterator ItemIterator) bool {
+	if h == nil {
+		return true
+	}
+	if less(t.comp, h.Item, key) {
+		return t.ascendEqual(h.Right, key, iterator)
+	}
+	if less(t.comp, key, h.Item) {
+		return t.ascendEqual(h.Left, key, iterator)
+	}
+	// Rotations may have put equal elements in both subtrees.
+	if !t.ascendEqual(h.Left, key, iterator) {
+		return false
+	}
+	if !iterator(h.Item) {
+		return false
+	}
+	return t.ascendEqual(h.Right, key, iterator)
+}
+
 // AscendGreaterOrEqual will call iterator once for each element greater or equal to
 // pivot in ascending order. It will stop whenever the iterator returns false.
 func (t *LLRB) AscendGreaterOrEqual(pivot Item, iterator ItemIterator) {
+	defer t.walk()()
 	t.ascendGreaterOrEqual(t.root, pivot, iterator)
 }
 
@@ -51,7 +105,111 @@ func (t *LLRB) ascendGreaterOrEqual(h *Node, pivot Item, iterator ItemIterator)
 	return t.ascendGreaterOrEqual(h.Right, pivot, iterator)
 }
 
+// AscendGreaterThan will call iterator once for each element greater than
+// pivot in ascending order, skipping every element equal to pivot. It will
+// stop whenever the iterator returns false.
+func (t *LLRB) AscendGreaterThan(pivot Item, iterator ItemIterator) {
+	defer t.walk()()
+	t.ascendGreaterThan(t.root, pivot, iterator)
+}
+
+func (t *LLRB) ascendGreaterThan(h *Node, pivot Item, iterator ItemIterator) bool {
+	if h == nil {
+		return true
+	}
+	if less(t.comp, pivot, h.Item) {
+		if !t.ascendGreaterThan(h.Left, pivot, iterator) {
+			return false
+		}
+		if !iterator(h.Item) {
+			return false
+		}
+	}
+	return t.ascendGreaterThan(h.Right, pivot, iterator)
+}
+
+// AscendSnapshot is like AscendGreaterOrEqual, except that the items are
+// collected before the iterator is first called, so the iterator may modify
+// the tree, e.g. to delete the items it visits. Items inserted by the iterator
+// are not visited, and deleted ones are still visited if they were collected.
+// The tradeoff is memory: a slice of all the items greater or equal to pivot
+// is allocated up front.
+func (t *LLRB) AscendSnapshot(pivot Item, iterator ItemIterator) {
+	var items []Item
+	t.AscendGreaterOrEqual(pivot, func(i Item) bool {
+		items = append(items, i)
+		return true
+	})
+	for _, i := range items {
+		if !iterator(i) {
+			return
+		}
+	}
+}
+
+// AscendBatch is like AscendGreaterOrEqual, but delivers the elements to fn
+// in slices of up to batchSize elements, which saves a call per element. The
+// slice is reused between calls, so fn must copy any elements it wants to
+// keep. The last slice may be shorter than batchSize.
+func (t *LLRB) AscendBatch(pivot Item, batchSize int, fn func(items []Item) bool) {
+	if batchSize <= 0 {
+		panic("llrb: batch size must be positive")
+	}
+	defer t.walk()()
+	b := &batcher{t: t, pivot: pivot, buf: make([]Item, 0, batchSize), fn: fn}
+	if b.ascend(t.root) && len(b.buf) > 0 {
+		fn(b.buf)
+	}
+}
+
+type batcher struct {
+	t     *LLRB
+	pivot Item
+	buf   []Item
+	fn    func([]Item) bool
+}
+
+func (b *batcher) ascend(h *Node) bool {
+	if h == nil {
+		return true
+	}
+	if less(b.t.comp, h.Item, b.pivot) {
+		return b.ascend(h.Right)
+	}
+	if !b.ascend(h.Left) || !b.add(h.Item) {
+		return false
+	}
+	// Everything to the right of h is greater or equal to the pivot.
+	return b.all(h.Right)
+}
+
+// all adds every element below h without comparing it to the pivot.
+func (b *batcher) all(h *Node) bool {
+	for h != nil {
+		if !b.all(h.Left) || !b.add(h.Item) {
+			return false
+		}
+		h = h.Right
+	}
+	return true
+}
+
+func (b *batcher) add(i Item) bool {
+	b.buf = append(b.buf, i)
+	if len(b.buf) < cap(b.buf) {
+		return true
+	}
+	if !b.fn(b.buf) {
+		return false
+	}
+	b.buf = b.buf[:0]
+	return true
+}
+
+// AscendLessThan will call iterator once for each element less than pivot
+// in ascending order. It will stop whenever the iterator returns false.
 func (t *LLRB) AscendLessThan(pivot Item, iterator ItemIterator) {
+	defer t.walk()()
 	t.ascendLessThan(t.root, pivot, iterator)
 }
 
@@ -62,18 +220,176 @@ func (t *LLRB) ascendLessThan(h *Node, pivot Item, iterator ItemIterator) bool {
 	if !t.ascendLessThan(h.Left, pivot, iterator) {
 		return false
 	}
+	if !less(t.comp, h.Item, pivot) {
+		return true
+	}
 	if !iterator(h.Item) {
 		return false
 	}
-	if less(t.comp, h.Item, pivot) {
-		return t.ascendLessThan(h.Left, pivot, iterator)
+	return t.ascendLessThan(h.Right, pivot, iterator)
+}
+
+// Descend will call iterator once for each element in the tree, in descending
+// order. It will stop whenever the iterator returns false.
+func (t *LLRB) Descend(iterator ItemIterator) {
+	defer t.walk()()
+	t.descend(t.root, iterator)
+}
+
+func (t *LLRB) descend(h *Node, iterator ItemIterator) bool {
+	if h == nil {
+		return true
 	}
-	return true
+	if !t.descend(h.Right, iterator) {
+		return false
+	}
+	if !iterator(h.Item) {
+		return false
+	}
+	return t.descend(h.Left, iterator)
 }
 
-// DescendLessOrEqual will call iterator once for each element less than the
-// pivot in descending order. It will stop whenever the iterator returns false.
+// ForEach is the same as Ascend. It compares no items, so the Inf sentinels
+// never reach the comparer.
+func (t *LLRB) ForEach(fn ItemIterator) { t.Ascend(fn) }
+
+// ForEachReverse is the same as Descend, and likewise compares no items.
+func (t *LLRB) ForEachReverse(fn ItemIterator) { t.Descend(fn) }
+
+// Fold calls fn on each element in the tree, in ascending order, passing the
+// result of each call on to the next, starting with init. It returns the
+// result of the last call, or init for an empty tree.
+func (t *LLRB) Fold(init interface{}, fn func(acc interface{}, item Item) interface{}) interface{} {
+	acc := init
+	t.Ascend(func(i Item) bool {
+		acc = fn(acc, i)
+		return true
+	})
+	return acc
+}
+
+// Any reports whether pred returns true for some element in the tree. It
+// calls pred in ascending order, and stops at the first element that
+// satisfies it.
+func (t *LLRB) Any(pred func(Item) bool) bool {
+	found := false
+	t.Ascend(func(i Item) bool {
+		found = pred(i)
+		return !found
+	})
+	return found
+}
+
+// Every reports whether pred returns true for every element in the tree. It
+// calls pred in ascending order, and stops at the first element that fails
+// it. It is true for an empty tree. It is not named All, since All returns
+// the iter.Seq of every element.
+func (t *LLRB) Every(pred func(Item) bool) bool {
+	ok := true
+	t.Ascend(func(i Item) bool {
+		ok = pred(i)
+		return ok
+	})
+	return ok
+}
+
+// ToSlice returns all the elements in the tree, in ascending order.
+func (t *LLRB) ToSlice() []Item {
+	items := make([]Item, 0, t.count)
+	t.Ascend(func(i Item) bool {
+		items = append(items, i)
+		return true
+	})
+	return items
+}
+
+// ToSliceDescending returns all the elements in the tree, in descending order.
+func (t *LLRB) ToSliceDescending() []Item {
+	items := make([]Item, 0, t.count)
+	t.Descend(func(i Item) bool {
+		items = append(items, i)
+		return true
+	})
+	return items
+}
+
+// Between returns the elements in the tree that are greater or equal to
+// greaterOrEqual and less than lessThan, in ascending order.
+func (t *LLRB) Between(greaterOrEqual, lessThan Item) []Item {
+	return t.AppendBetween(nil, greaterOrEqual, lessThan)
+}
+
+// AppendBetween is like Between, but appends the elements to dst and returns
+// the extended slice. It does not allocate if dst has room for them.
+func (t *LLRB) AppendBetween(dst []Item, greaterOrEqual, lessThan Item) []Item {
+	t.AscendRange(greaterOrEqual, lessThan, func(i Item) bool {
+		dst = append(dst, i)
+		return true
+	})
+	return dst
+}
+
+// MinN returns the k smallest elements in the tree, in ascending order, or
+// every element if there are fewer than k. It stops the traversal as soon as
+// it has k elements.
+func (t *LLRB) MinN(k int) []Item {
+	if k <= 0 {
+		return nil
+	}
+	if k > t.count {
+		k = t.count
+	}
+	return t.AppendMinN(make([]Item, 0, k), k)
+}
+
+// AppendMinN is like MinN, but appends the elements to dst and returns the
+// extended slice.
+func (t *LLRB) AppendMinN(dst []Item, k int) []Item {
+	if k <= 0 {
+		return dst
+	}
+	n := 0
+	t.Ascend(func(i Item) bool {
+		dst = append(dst, i)
+		n++
+		return n < k
+	})
+	return dst
+}
+
+// MaxN returns the k largest elements in the tree, in descending order, or
+// every element if there are fewer than k. It stops the traversal as soon as
+// it has k elements.
+func (t *LLRB) MaxN(k int) []Item {
+	if k <= 0 {
+		return nil
+	}
+	if k > t.count {
+		k = t.count
+	}
+	return t.AppendMaxN(make([]Item, 0, k), k)
+}
+
+// AppendMaxN is like MaxN, but appends the elements to dst and returns the
+// extended slice.
+func (t *LLRB) AppendMaxN(dst []Item, k int) []Item {
+	if k <= 0 {
+		return dst
+	}
+	n := 0
+	t.Descend(func(i Item) bool {
+		dst = append(dst, i)
+		n++
+		return n < k
+	})
+	return dst
+}
+
+// DescendLessOrEqual will call iterator once for each element less than or
+// equal to pivot in descending order. It will stop whenever the iterator
+// returns false.
 func (t *LLRB) DescendLessOrEqual(pivot Item, iterator ItemIterator) {
+	defer t.walk()()
 	t.descendLessOrEqual(t.root, pivot, iterator)
 }
 
@@ -81,7 +397,7 @@ func (t *LLRB) descendLessOrEqual(h *Node, pivot Item, iterator ItemIterator) bo
 	if h == nil {
 		return true
 	}
-	if less(t.comp, h.Item, pivot) || !less(t.comp, pivot, h.Item) {
+	if !less(t.comp, pivot, h.Item) {
 		if !t.descendLessOrEqual(h.Right, pivot, iterator) {
 			return false
 		}
@@ -91,3 +407,264 @@ func (t *LLRB) descendLessOrEqual(h *Node, pivot Item, iterator ItemIterator) bo
 	}
 	return t.descendLessOrEqual(h.Left, pivot, iterator)
 }
+
+// DescendLessThan will call iterator once for each element less than pivot
+// in descending order, skipping every element equal to pivot. It will stop
+// whenever the iterator returns false.
+func (t *LLRB) DescendLessThan(pivot Item, iterator ItemIterator) {
+	defer t.walk()()
+	t.descendLessThan(t.root, pivot, iterator)
+}
+
+func (t *LLRB) descendLessThan(h *Node, pivot Item, iterator ItemIterator) bool {
+	if h == nil {
+		return true
+	}
+	if less(t.comp, h.Item, pivot) {
+		if !t.descendLessThan(h.Right, pivot, iterator) {
+			return false
+		}
+		if !iterator(h.Item) {
+			return false
+		}
+	}
+	return t.descendLessThan(h.Left, pivot, iterator)
+}
+
+// DescendGreaterThan will call iterator once for each element greater than
+// pivot in descending order. It will stop whenever the iterator returns false.
+func (t *LLRB) DescendGreaterThan(pivot Item, iterator ItemIterator) {
+	defer t.walk()()
+	t.descendGreaterThan(t.root, pivot, iterator)
+}
+
+func (t *LLRB) descendGreaterThan(h *Node, pivot Item, iterator ItemIterator) bool {
+	if h == nil {
+		return true
+	}
+	if !t.descendGreaterThan(h.Right, pivot, iterator) {
+		return false
+	}
+	if !less(t.comp, pivot, h.Item) {
+		return true
+	}
+	if !iterator(h.Item) {
+		return false
+	}
+	return t.descendGreaterThan(h.Left, pivot, iterator)
+}
+
+// DescendRange will call iterator once for each element less than or equal to
+// lessOrEqual and greater than greaterThan, in descending order. Subtrees that
+// cannot hold such elements are skipped. It will stop whenever the iterator
+// returns false.
+func (t *LLRB) DescendRange(lessOrEqual, greaterThan Item, iterator ItemIterator) {
+	defer t.walk()()
+	t.descendRange(t.root, lessOrEqual, greaterThan, iterator)
+}
+
+func (t *LLRB) descendRange(h *Node, sup, inf Item, iterator ItemIterator) bool {
+	if h == nil {
+		return true
+	}
+	if less(t.comp, sup, h.Item) {
+		return t.descendRange(h.Left, sup, inf, iterator)
+	}
+	if !less(t.comp, inf, h.Item) {
+		return t.descendRange(h.Right, sup, inf, iterator)
+	}
+
+	if !t.descendRange(h.Right, sup, inf, iterator) {
+		return false
+	}
+	if !iterator(h.Item) {
+		return false
+	}
+	return t.descendRange(h.Left, sup, inf, iterator)
+}
+
+// Iterator is a cursor over the items of a tree in sorted order, which can
+// move in either direction. It keeps an explicit stack of the nodes on the
+// path from the root to the current position, so it uses O(height) memory.
+// Mutating the tree while an Iterator is in use invalidates the Iterator, and
+// moving it or reading its item then panics (see SetModCheck). Call Reset,
+// First, Last or Seek before using it again.
+type Iterator struct {
+	t     *LLRB
+	stack []*Node // Path from the root to the current node
+	end   int     // If the stack is empty, -1 before the smallest item and +1 after the largest
+	mods  uint64  // The tree's modification count when the iterator was positioned
+}
+
+// NewIterator returns an Iterator positioned before the smallest item in the
+// tree, so that the first call to Next moves to the smallest item.
+func (t *LLRB) NewIterator() *Iterator {
+	it := &Iterator{t: t}
+	it.Reset()
+	return it
+}
+
+// Reset positions the iterator before the smallest item in the tree.
+func (it *Iterator) Reset() {
+	it.mods = it.t.mods
+	it.stack = it.stack[:0]
+	it.end = -1
+}
+
+// First moves the iterator to the smallest item in the tree. It returns false
+// if the tree is empty.
+func (it *Iterator) First() bool {
+	it.mods = it.t.mods
+	it.stack = it.stack[:0]
+	it.pushLeft(it.t.root)
+	return len(it.stack) > 0
+}
+
+// Last moves the iterator to the largest item in the tree. It returns false
+// if the tree is empty.
+func (it *Iterator) Last() bool {
+	it.mods = it.t.mods
+	it.stack = it.stack[:0]
+	it.pushRight(it.t.root)
+	return len(it.stack) > 0
+}
+
+// Seek moves the iterator to the first item that is greater or equal to key.
+// If there is no such item, it returns false and leaves the iterator after
+// the largest item, so that Prev moves to the largest item less than key.
+func (it *Iterator) Seek(key Item) bool {
+	it.mods = it.t.mods
+	it.stack = it.stack[:0]
+	found := 0
+	h := it.t.root
+	for h != nil {
+		it.stack = append(it.stack, h)
+		if less(it.t.comp, h.Item, key) {
+			h = h.Right
+		} else {
+			found = len(it.stack)
+			h = h.Left
+		}
+	}
+	it.stack = it.stack[:found]
+	it.end = 1
+	return found > 0
+}
+
+// Next moves the iterator to the next item in ascending order. It returns
+// false once the iterator moves past the largest item.
+func (it *Iterator) Next() bool {
+	it.check()
+	if len(it.stack) == 0 {
+		if it.end < 0 {
+			return it.First()
+		}
+		return false
+	}
+	h := it.stack[len(it.stack)-1]
+	if h.Right != nil {
+		it.pushLeft(h.Right)
+		return true
+	}
+	for {
+		it.stack = it.stack[:len(it.stack)-1]
+		if len(it.stack) == 0 {
+			it.end = 1
+			return false
+		}
+		if it.stack[len(it.stack)-1].Left == h {
+			return true
+		}
+		h = it.stack[len(it.stack)-1]
+	}
+}
+
+// Prev moves the iterator to the previous item in ascending order. It returns
+// false once the iterator moves before the smallest item.
+func (it *Iterator) Prev() bool {
+	it.check()
+	if len(it.stack) == 0 {
+		if it.end > 0 {
+			return it.Last()
+		}
+		return false
+	}
+	h := it.stack[len(it.stack)-1]
+	if h.Left != nil {
+		it.pushRight(h.Left)
+		return true
+	}
+	for {
+		it.stack = it.stack[:len(it.stack)-1]
+		if len(it.stack) == 0 {
+			it.end = -1
+			return false
+		}
+		if it.stack[len(it.stack)-1].Right == h {
+			return true
+		}
+		h = it.stack[len(it.stack)-1]
+	}
+}
+
+// Item returns the item at the current position, or nil if the iterator is
+// not positioned at an item.
+func (it *Iterator) Item() Item {
+	it.check()
+	if len(it.stack) == 0 {
+		return nil
+	}
+	return it.stack[len(it.stack)-1].Item
+}
+
+// check panics if the tree has been modified since the iterator was positioned.
+func (it *Iterator) check() {
+	if it.mods != it.t.mods && !it.t.noModCheck {
+		panic("llrb: tree modified during iteration")
+	}
+}
+
+func (it *Iterator) pushLeft(h *Node) {
+	for h != nil {
+		it.stack = append(it.stack, h)
+		h = h.Left
+	}
+}
+
+func (it *Iterator) pushRight(h *Node) {
+	for h != nil {
+		it.stack = append(it.stack, h)
+		h = h.Right
+	}
+}
+
+// Pull returns a pull-style iterator over the elements greater or equal to
+// pivot, in ascending order, with the contract of iter.Pull: each call to next
+// returns the following element and true, or nil and false once there are no
+// more, and stop ends the iteration early. It is built on an Iterator, so it
+// needs no goroutine and O(height) memory.
+func (t *LLRB) Pull(pivot Item) (next func() (Item, bool), stop func()) {
+	var it *Iterator
+	done := false
+	next = func() (Item, bool) {
+		if done {
+			return nil, false
+		}
+		var ok bool
+		if it == nil {
+			it = t.NewIterator()
+			ok = it.Seek(pivot)
+		} else {
+			ok = it.Next()
+		}
+		if !ok {
+			stop()
+			return nil, false
+		}
+		return it.Item(), true
+	}
+	stop = func() {
+		done, it = true, nil
+	}
+	return next, stop
+}
@@ -1,7 +1,10 @@
 package llrb
 
 import (
+	"math/rand"
 	"reflect"
+	"sort"
+	"sync"
 	"testing"
 )
 
@@ -74,3 +77,1022 @@ func TestDescendLessOrEqual(t *testing.T) {
 		t.Errorf("expected %v but got %v", expected, ary)
 	}
 }
+
+func TestForEach(t *testing.T) {
+	calls := 0
+	tree := New(func(a, b interface{}) bool {
+		calls++
+		return a.(Int) < b.(Int)
+	})
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	calls = 0
+	var asc, desc []Item
+	tree.ForEach(func(i Item) bool {
+		asc = append(asc, i)
+		return true
+	})
+	tree.ForEachReverse(func(i Item) bool {
+		desc = append(desc, i)
+		return len(desc) < 50
+	})
+	if calls != 0 {
+		t.Errorf("expected no comparisons, got %d", calls)
+	}
+	if len(asc) != 100 || len(desc) != 50 {
+		t.Fatalf("visited %d and %d items, expected 100 and 50", len(asc), len(desc))
+	}
+	for k := range desc {
+		if asc[k] != Int(k) || desc[k] != Int(99-k) {
+			t.Fatalf("bad order at %d: %v, %v", k, asc[k], desc[k])
+		}
+	}
+}
+
+func TestAscendGreaterOrEqualPivots(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range []int{5, 2, 8, 1, 9} {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	var ary []Item
+	collect := func(i Item) bool {
+		ary = append(ary, i)
+		return true
+	}
+	tree.AscendGreaterOrEqual(tree.Min(), collect)
+	expected := []Item{Int(1), Int(2), Int(5), Int(8), Int(9)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	ary = nil
+	tree.AscendGreaterOrEqual(Inf(-1), collect)
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	ary = nil
+	tree.AscendGreaterOrEqual(Int(6), collect)
+	expected = []Item{Int(8), Int(9)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	ary = nil
+	tree.AscendGreaterOrEqual(Inf(1), collect)
+	if len(ary) != 0 {
+		t.Errorf("expected no items but got %v", ary)
+	}
+}
+
+func TestAscendGreaterOrEqualEarlyStop(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 100; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	var ary []Item
+	tree.AscendGreaterOrEqual(Int(10), func(i Item) bool {
+		ary = append(ary, i)
+		return len(ary) < 3
+	})
+	expected := []Item{Int(10), Int(11), Int(12)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+}
+
+func TestAscendGreaterOrEqualEmpty(t *testing.T) {
+	tree := New(func(a, b interface{}) bool {
+		t.Fatalf("comparer called on empty tree")
+		return false
+	})
+	tree.AscendGreaterOrEqual(Inf(-1), func(i Item) bool {
+		t.Errorf("visited %v in empty tree", i)
+		return true
+	})
+}
+
+func TestAscendGreaterOrEqualSentinelPivot(t *testing.T) {
+	tree := New(func(a, b interface{}) bool {
+		return a.(Int) < b.(Int) // panics if handed a sentinel
+	})
+	for i := 0; i < 10; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	n := 0
+	tree.AscendGreaterOrEqual(Inf(-1), func(i Item) bool {
+		n++
+		return true
+	})
+	if n != 10 {
+		t.Errorf("expected 10 items, got %d", n)
+	}
+}
+
+func TestAscendRange(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 10; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	var ary []Item
+	collect := func(i Item) bool {
+		ary = append(ary, i)
+		return true
+	}
+	tree.AscendRange(Int(3), Int(6), collect)
+	expected := []Item{Int(3), Int(4), Int(5)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	ary = nil
+	tree.AscendRange(Int(6), Int(3), collect)
+	if len(ary) != 0 {
+		t.Errorf("expected no items for inverted range but got %v", ary)
+	}
+	ary = nil
+	tree.AscendRange(Inf(-1), Int(2), collect)
+	expected = []Item{Int(0), Int(1)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	ary = nil
+	tree.AscendRange(Int(8), Inf(1), collect)
+	expected = []Item{Int(8), Int(9)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+}
+
+func TestAscendRangePrunes(t *testing.T) {
+	calls := 0
+	tree := New(func(a, b interface{}) bool {
+		calls++
+		return a.(Int) < b.(Int)
+	})
+	n := 1 << 12
+	for i := 0; i < n; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	calls = 0
+	k := 0
+	tree.AscendRange(Int(100), Int(110), func(i Item) bool {
+		k++
+		return true
+	})
+	if k != 10 {
+		t.Errorf("expected 10 items, got %d", k)
+	}
+	if calls > 200 {
+		t.Errorf("range scan made %d comparisons, expected pruning", calls)
+	}
+}
+
+type tagged struct {
+	key Int
+	tag int
+}
+
+func lessTagged(a, b interface{}) bool { return a.(tagged).key < b.(tagged).key }
+
+func TestDescendLessOrEqualDuplicates(t *testing.T) {
+	tree := New(lessTagged)
+	tree.InsertNoReplace(tagged{2, 0})
+	tree.InsertNoReplace(tagged{1, 0})
+	tree.InsertNoReplace(tagged{2, 1})
+	tree.InsertNoReplace(tagged{3, 0})
+	tree.InsertNoReplace(tagged{2, 2})
+	var ary []Item
+	tree.DescendLessOrEqual(tagged{2, -1}, func(i Item) bool {
+		ary = append(ary, i)
+		return true
+	})
+	// Duplicates are visited newest first, the reverse of insertion order.
+	expected := []Item{tagged{2, 2}, tagged{2, 1}, tagged{2, 0}, tagged{1, 0}}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	ary = nil
+	tree.DescendLessOrEqual(Inf(1), func(i Item) bool {
+		ary = append(ary, i)
+		return len(ary) < 2
+	})
+	expected = []Item{tagged{3, 0}, tagged{2, 2}}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+}
+
+func TestDescendLessOrEqualBelowMin(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range []int{4, 6, 1, 3} {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	for _, pivot := range []Item{Int(0), Inf(-1)} {
+		tree.DescendLessOrEqual(pivot, func(i Item) bool {
+			t.Errorf("pivot %v: unexpected visit of %v", pivot, i)
+			return true
+		})
+	}
+	var first Item
+	tree.DescendLessOrEqual(Int(3), func(i Item) bool {
+		first = i
+		return false
+	})
+	if first != Int(3) {
+		t.Errorf("expected 3 to be visited first, got %v", first)
+	}
+}
+
+func TestAscendRangeEdges(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 1; i <= 5; i++ {
+		tree.ReplaceOrInsert(Int(i * 10))
+	}
+	collect := func(lo, hi Item) []Item {
+		var ary []Item
+		tree.AscendRange(lo, hi, func(i Item) bool {
+			ary = append(ary, i)
+			return true
+		})
+		return ary
+	}
+	if ary := collect(Int(30), Int(30)); len(ary) != 0 {
+		t.Errorf("expected empty range, got %v", ary)
+	}
+	expected := []Item{Int(10), Int(20)}
+	if ary := collect(Int(0), Int(25)); !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	expected = []Item{Int(40), Int(50)}
+	if ary := collect(Int(35), Int(100)); !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	expected = []Item{Int(20), Int(30)}
+	if ary := collect(Int(20), Int(40)); !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+}
+
+func benchmarkRangeTree(b *testing.B) *LLRB {
+	b.StopTimer()
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 100000; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	b.StartTimer()
+	return tree
+}
+
+func BenchmarkAscendRange(b *testing.B) {
+	tree := benchmarkRangeTree(b)
+	for i := 0; i < b.N; i++ {
+		tree.AscendRange(Int(50000), Int(50100), func(Item) bool { return true })
+	}
+}
+
+func BenchmarkAscendFilter(b *testing.B) {
+	tree := benchmarkRangeTree(b)
+	for i := 0; i < b.N; i++ {
+		tree.AscendGreaterOrEqual(Inf(-1), func(item Item) bool {
+			j := item.(Int)
+			_ = j >= 50000 && j < 50100
+			return true
+		})
+	}
+}
+
+func TestDescendRange(t *testing.T) {
+	tree := New(lessTagged)
+	for k := 1; k <= 4; k++ {
+		tree.InsertNoReplace(tagged{Int(k), 0})
+		tree.InsertNoReplace(tagged{Int(k), 1})
+	}
+	collect := func(hi, lo Item) []Item {
+		var ary []Item
+		tree.DescendRange(hi, lo, func(i Item) bool {
+			ary = append(ary, i)
+			return true
+		})
+		return ary
+	}
+	expected := []Item{tagged{3, 1}, tagged{3, 0}, tagged{2, 1}, tagged{2, 0}}
+	if ary := collect(tagged{3, -1}, tagged{1, -1}); !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	if ary := collect(tagged{1, -1}, tagged{3, -1}); len(ary) != 0 {
+		t.Errorf("expected nothing for inverted bounds, got %v", ary)
+	}
+	expected = []Item{tagged{4, 1}, tagged{4, 0}}
+	if ary := collect(Inf(1), tagged{3, -1}); !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	expected = []Item{tagged{1, 1}, tagged{1, 0}}
+	if ary := collect(tagged{1, -1}, Inf(-1)); !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+}
+
+func TestIterator(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 10000
+	perm := rand.Perm(n)
+	for _, i := range perm {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	sort.Ints(perm)
+	it := tree.NewIterator()
+	for pass := 0; pass < 2; pass++ {
+		for _, want := range perm {
+			if !it.Next() || it.Item() != Int(want) {
+				t.Fatalf("expected %d, got %v", want, it.Item())
+			}
+		}
+		if it.Next() {
+			t.Fatalf("expected exhausted iterator, got %v", it.Item())
+		}
+		it.Reset()
+	}
+}
+
+func TestIteratorSeek(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	it := tree.NewIterator()
+	if it.First() || it.Last() || it.Seek(Int(0)) || it.Item() != nil {
+		t.Errorf("expected empty tree iterator to be exhausted")
+	}
+	for i := 0; i < 100; i += 10 {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	if !it.First() || it.Item() != Int(0) {
+		t.Errorf("First: expected 0, got %v", it.Item())
+	}
+	if !it.Last() || it.Item() != Int(90) {
+		t.Errorf("Last: expected 90, got %v", it.Item())
+	}
+	if it.Next() {
+		t.Errorf("expected Next after Last to be exhausted, got %v", it.Item())
+	}
+	if !it.Seek(Int(40)) || it.Item() != Int(40) {
+		t.Errorf("Seek(40): expected 40, got %v", it.Item())
+	}
+	if !it.Seek(Int(45)) || it.Item() != Int(50) {
+		t.Errorf("Seek(45): expected 50, got %v", it.Item())
+	}
+	if !it.Next() || it.Item() != Int(60) {
+		t.Errorf("expected 60 after 50, got %v", it.Item())
+	}
+	if it.Seek(Int(95)) {
+		t.Errorf("Seek(95): expected no item, got %v", it.Item())
+	}
+}
+
+func TestIteratorMerge(t *testing.T) {
+	a, b := New(NaturalSortLessInt), New(NaturalSortLessInt)
+	for _, i := range rand.Perm(200) {
+		if i%3 == 0 {
+			a.ReplaceOrInsert(Int(i))
+		} else {
+			b.ReplaceOrInsert(Int(i))
+		}
+	}
+	ia, ib := a.NewIterator(), b.NewIterator()
+	okA, okB := ia.Next(), ib.Next()
+	j := 0
+	for okA || okB {
+		var item Item
+		if okA && (!okB || ia.Item().(Int) < ib.Item().(Int)) {
+			item, okA = ia.Item(), ia.Next()
+		} else {
+			item, okB = ib.Item(), ib.Next()
+		}
+		if item != Int(j) {
+			t.Fatalf("expected %d, got %v", j, item)
+		}
+		j++
+	}
+	if j != 200 {
+		t.Errorf("expected 200 merged items, got %d", j)
+	}
+}
+
+func TestAscend(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	tree.Ascend(func(i Item) bool {
+		t.Errorf("visited %v in empty tree", i)
+		return true
+	})
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	j := 0
+	tree.Ascend(func(i Item) bool {
+		if i != Int(j) {
+			t.Fatalf("expected %d, got %v", j, i)
+		}
+		j++
+		return j < 50
+	})
+	if j != 50 {
+		t.Errorf("expected traversal to stop after 50 items, got %d", j)
+	}
+}
+
+func TestAscendMatchesAscendGreaterOrEqual(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(500) {
+		tree.ReplaceOrInsert(Int(rand.Intn(1000) + i))
+	}
+	var all, pivoted []Item
+	tree.Ascend(func(i Item) bool {
+		all = append(all, i)
+		return true
+	})
+	tree.AscendGreaterOrEqual(tree.Min(), func(i Item) bool {
+		pivoted = append(pivoted, i)
+		return true
+	})
+	if len(all) != tree.Len() || !reflect.DeepEqual(all, pivoted) {
+		t.Errorf("Ascend visited %d items, AscendGreaterOrEqual(Min()) %d", len(all), len(pivoted))
+	}
+}
+
+func TestAscendMutationPanics(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 10; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic when deleting during Ascend")
+			}
+		}()
+		tree.Ascend(func(i Item) bool {
+			tree.Delete(i)
+			return true
+		})
+	}()
+	if tree.Len() != 10 {
+		t.Errorf("expected tree to be unchanged, len is %d", tree.Len())
+	}
+	tree.Delete(Int(0)) // the tree is mutable again once the traversal ends
+	if tree.Len() != 9 {
+		t.Errorf("expected len 9, got %d", tree.Len())
+	}
+}
+
+func TestAscendNoAllocs(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 1000; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	n := 0
+	f := func(Item) bool { n++; return true }
+	allocs := testing.AllocsPerRun(10, func() { tree.Ascend(f) })
+	if allocs > 1 {
+		t.Errorf("expected Ascend not to allocate per item, got %v allocs", allocs)
+	}
+}
+
+func TestDescend(t *testing.T) {
+	tree := New(lessTagged)
+	for copy := 0; copy < 3; copy++ {
+		for _, k := range rand.Perm(20) {
+			tree.InsertNoReplace(tagged{Int(k), copy})
+		}
+	}
+	seen := map[tagged]bool{}
+	prev := Int(20)
+	tree.Descend(func(i Item) bool {
+		item := i.(tagged)
+		if item.key > prev {
+			t.Fatalf("%v visited after %v", item.key, prev)
+		}
+		if seen[item] {
+			t.Fatalf("%v visited twice", item)
+		}
+		seen[item] = true
+		prev = item.key
+		return true
+	})
+	if len(seen) != 60 {
+		t.Errorf("expected 60 items, got %d", len(seen))
+	}
+	var ary []Item
+	tree.Descend(func(i Item) bool {
+		ary = append(ary, i.(tagged).key)
+		return len(ary) < 4
+	})
+	expected := []Item{Int(19), Int(19), Int(19), Int(18)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+}
+
+func TestDescendMatchesDescendLessOrEqual(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	tree.Descend(func(i Item) bool {
+		t.Errorf("visited %v in empty tree", i)
+		return true
+	})
+	for _, i := range rand.Perm(500) {
+		tree.ReplaceOrInsert(Int(rand.Intn(1000) + i))
+	}
+	var all, pivoted []Item
+	tree.Descend(func(i Item) bool {
+		all = append(all, i)
+		return true
+	})
+	tree.DescendLessOrEqual(tree.Max(), func(i Item) bool {
+		pivoted = append(pivoted, i)
+		return true
+	})
+	if len(all) != tree.Len() || !reflect.DeepEqual(all, pivoted) {
+		t.Errorf("Descend visited %d items, DescendLessOrEqual(Max()) %d", len(all), len(pivoted))
+	}
+}
+
+func TestAscendLessThan(t *testing.T) {
+	tree := New(func(a, b interface{}) bool {
+		return a.(Int) < b.(Int) // panics if handed a sentinel
+	})
+	for _, i := range rand.Perm(10) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	var ary []Item
+	collect := func(i Item) bool {
+		ary = append(ary, i)
+		return true
+	}
+	tree.AscendLessThan(Int(4), collect)
+	expected := []Item{Int(0), Int(1), Int(2), Int(3)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	ary = nil
+	tree.AscendLessThan(Inf(1), collect)
+	if len(ary) != 10 {
+		t.Errorf("expected all 10 items, got %v", ary)
+	}
+	ary = nil
+	tree.AscendLessThan(Int(0), collect)
+	if len(ary) != 0 {
+		t.Errorf("expected no items, got %v", ary)
+	}
+}
+
+func TestDescendGreaterThanRandom(t *testing.T) {
+	for round := 0; round < 50; round++ {
+		tree := New(NaturalSortLessInt)
+		var keys []int
+		for i := 0; i < 200; i++ {
+			k := rand.Intn(100)
+			keys = append(keys, k)
+			tree.InsertNoReplace(Int(k))
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+		pivot := rand.Intn(110) - 5
+		var expected, ary []Item
+		for _, k := range keys {
+			if k > pivot {
+				expected = append(expected, Int(k))
+			}
+		}
+		tree.DescendGreaterThan(Int(pivot), func(i Item) bool {
+			ary = append(ary, i)
+			return true
+		})
+		if !reflect.DeepEqual(ary, expected) {
+			t.Fatalf("pivot %d: expected %v but got %v", pivot, expected, ary)
+		}
+	}
+}
+
+func TestIteratorPrev(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i * 10))
+	}
+	it := tree.NewIterator()
+	if !it.Seek(Int(455)) || it.Item() != Int(460) {
+		t.Fatalf("Seek(455): expected 460, got %v", it.Item())
+	}
+	if !it.Prev() || it.Item() != Int(450) {
+		t.Errorf("Prev after Seek(455): expected 450, got %v", it.Item())
+	}
+	if it.Seek(Int(5000)) {
+		t.Errorf("Seek(5000): expected no item, got %v", it.Item())
+	}
+	if !it.Prev() || it.Item() != Int(990) {
+		t.Errorf("Prev after Seek past Max: expected 990, got %v", it.Item())
+	}
+
+	it.First()
+	if it.Prev() {
+		t.Errorf("expected Prev at the minimum to fail, got %v", it.Item())
+	}
+	if !it.Next() || it.Item() != Int(0) {
+		t.Errorf("expected Next to return to the minimum, got %v", it.Item())
+	}
+
+	// Alternate steps, moving forward two and back one, over the whole tree.
+	it.First()
+	pos := 0
+	for pos < 980 {
+		for k := 0; k < 2; k++ {
+			if !it.Next() {
+				t.Fatalf("Next failed after %d", pos)
+			}
+			pos += 10
+			if it.Item() != Int(pos) {
+				t.Fatalf("Next: expected %d, got %v", pos, it.Item())
+			}
+		}
+		if !it.Prev() {
+			t.Fatalf("Prev failed after %d", pos)
+		}
+		pos -= 10
+		if it.Item() != Int(pos) {
+			t.Fatalf("Prev: expected %d, got %v", pos, it.Item())
+		}
+	}
+	j := 990
+	for it.Last(); it.Item() != nil; it.Prev() {
+		if it.Item() != Int(j) {
+			t.Fatalf("expected %d, got %v", j, it.Item())
+		}
+		j -= 10
+	}
+	if j != -10 {
+		t.Errorf("backward walk stopped at %d", j)
+	}
+}
+
+func TestIteratorModified(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 10; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	it := tree.NewIterator()
+	it.Next()
+	tree.Delete(Int(5))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic when iterating a modified tree")
+			}
+		}()
+		it.Next()
+	}()
+	if !it.Seek(Int(5)) || it.Item() != Int(6) {
+		t.Errorf("expected a repositioned iterator to work, got %v", it.Item())
+	}
+
+	tree.SetModCheck(false)
+	tree.ReplaceOrInsert(Int(5))
+	it.Next() // no panic
+	tree.Ascend(func(i Item) bool {
+		tree.ReplaceOrInsert(i) // no panic
+		return false
+	})
+}
+
+func TestFoldAnyEvery(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	sum := func(acc interface{}, i Item) interface{} { return acc.(int) + int(i.(Int)) }
+	if s := tree.Fold(7, sum); s != 7 {
+		t.Errorf("expected Fold of an empty tree to return init, got %v", s)
+	}
+	if tree.Any(func(Item) bool { return true }) || !tree.Every(func(Item) bool { return false }) {
+		t.Errorf("expected Any false and Every true for an empty tree")
+	}
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	if s := tree.Fold(0, sum); s != 4950 {
+		t.Errorf("expected sum 4950, got %v", s)
+	}
+	calls := 0
+	counting := func(pred func(Int) bool) func(Item) bool {
+		calls = 0
+		return func(i Item) bool {
+			calls++
+			return pred(i.(Int))
+		}
+	}
+	if !tree.Any(counting(func(i Int) bool { return i == 10 })) || calls != 11 {
+		t.Errorf("expected Any to stop after 11 calls, got %d", calls)
+	}
+	if tree.Any(counting(func(i Int) bool { return i > 100 })) || calls != 100 {
+		t.Errorf("expected Any to check all 100 items, got %d", calls)
+	}
+	if tree.Every(counting(func(i Int) bool { return i < 20 })) || calls != 21 {
+		t.Errorf("expected Every to stop after 21 calls, got %d", calls)
+	}
+	if !tree.Every(counting(func(i Int) bool { return i >= 0 })) || calls != 100 {
+		t.Errorf("expected Every to check all 100 items, got %d", calls)
+	}
+}
+
+func TestToSlice(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if items := tree.ToSlice(); len(items) != 0 {
+		t.Errorf("expected no items, got %v", items)
+	}
+	var ints []int
+	for i := 0; i < 500; i++ {
+		k := rand.Intn(1000)
+		ints = append(ints, k)
+		tree.InsertNoReplace(Int(k))
+	}
+	sort.Ints(ints)
+	var expected []Item
+	for _, i := range ints {
+		expected = append(expected, Int(i))
+	}
+	if items := tree.ToSlice(); !reflect.DeepEqual(items, expected) {
+		t.Errorf("ToSlice: expected %v but got %v", expected, items)
+	}
+	for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+		expected[i], expected[j] = expected[j], expected[i]
+	}
+	if items := tree.ToSliceDescending(); !reflect.DeepEqual(items, expected) {
+		t.Errorf("ToSliceDescending: expected %v but got %v", expected, items)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 1000; i++ {
+		tree.InsertNoReplace(Int(rand.Intn(500)))
+	}
+	bounds := []Item{Inf(-1), Int(-1), Int(0), Int(17), Int(250), Int(499), Int(500), Inf(1)}
+	for _, lo := range bounds {
+		for _, hi := range bounds {
+			var expected []Item
+			tree.AscendRange(lo, hi, func(i Item) bool {
+				expected = append(expected, i)
+				return true
+			})
+			if items := tree.Between(lo, hi); !reflect.DeepEqual(items, expected) {
+				t.Errorf("Between(%v, %v): expected %v but got %v", lo, hi, expected, items)
+			}
+		}
+	}
+	dst := make([]Item, 0, tree.Len())
+	allocs := testing.AllocsPerRun(10, func() {
+		dst = tree.AppendBetween(dst[:0], Int(100), Int(400))
+	})
+	if allocs != 0 {
+		t.Errorf("expected AppendBetween to reuse dst, got %v allocations", allocs)
+	}
+	if n := tree.CountRange(Int(100), Int(400)); len(dst) != n {
+		t.Errorf("expected %d items, got %d", n, len(dst))
+	}
+}
+
+func TestMinMaxN(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if items := tree.MinN(3); len(items) != 0 {
+		t.Errorf("expected no items from an empty tree, got %v", items)
+	}
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	if items, expected := tree.MinN(3), []Item{Int(0), Int(1), Int(2)}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("MinN: expected %v but got %v", expected, items)
+	}
+	if items, expected := tree.MaxN(3), []Item{Int(99), Int(98), Int(97)}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("MaxN: expected %v but got %v", expected, items)
+	}
+	if tree.MinN(0) != nil || tree.MaxN(-1) != nil {
+		t.Errorf("expected nil for k <= 0")
+	}
+	if items := tree.MinN(1000); !reflect.DeepEqual(items, tree.ToSlice()) {
+		t.Errorf("expected every item for k > Len")
+	}
+	if items := tree.MaxN(1000); !reflect.DeepEqual(items, tree.ToSliceDescending()) {
+		t.Errorf("expected every item for k > Len")
+	}
+	dst := []Item{Int(-1)}
+	dst = tree.AppendMaxN(dst, 2)
+	if expected := []Item{Int(-1), Int(99), Int(98)}; !reflect.DeepEqual(dst, expected) {
+		t.Errorf("AppendMaxN: expected %v but got %v", expected, dst)
+	}
+	if dst := tree.AppendMinN(dst[:0], 0); len(dst) != 0 {
+		t.Errorf("AppendMinN with k = 0 appended %v", dst)
+	}
+}
+
+func BenchmarkMinN(b *testing.B) {
+	tree := benchmarkRangeTree(b)
+	for i := 0; i < b.N; i++ {
+		tree.MinN(10)
+	}
+}
+
+func BenchmarkMinNToSlice(b *testing.B) {
+	tree := benchmarkRangeTree(b)
+	for i := 0; i < b.N; i++ {
+		_ = tree.ToSlice()[:10]
+	}
+}
+
+func TestAscendBatch(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	var sizes []int
+	j := 10
+	tree.AscendBatch(Int(10), 16, func(items []Item) bool {
+		sizes = append(sizes, len(items))
+		for _, i := range items {
+			if i != Int(j) {
+				t.Fatalf("expected %d, got %v", j, i)
+			}
+			j++
+		}
+		return true
+	})
+	expected := []int{16, 16, 16, 16, 16, 10}
+	if !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("expected batches of %v, got %v", expected, sizes)
+	}
+	calls := 0
+	tree.AscendBatch(Inf(-1), 16, func(items []Item) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("expected the traversal to stop after one batch, got %d", calls)
+	}
+}
+
+func BenchmarkAscendItems(b *testing.B) {
+	tree := benchmarkRangeTree(b)
+	for i := 0; i < b.N; i++ {
+		n := 0
+		tree.AscendGreaterOrEqual(Inf(-1), func(Item) bool {
+			n++
+			return true
+		})
+	}
+}
+
+func BenchmarkAscendBatch(b *testing.B) {
+	tree := benchmarkRangeTree(b)
+	for i := 0; i < b.N; i++ {
+		n := 0
+		tree.AscendBatch(Inf(-1), 256, func(items []Item) bool {
+			n += len(items)
+			return true
+		})
+	}
+}
+
+func TestAscendEqual(t *testing.T) {
+	tree := New(lessTagged)
+	for i := 0; i < 1000; i++ {
+		tree.InsertNoReplace(tagged{Int(rand.Intn(5)), -1})
+		tree.InsertNoReplace(tagged{5, i})
+		tree.InsertNoReplace(tagged{Int(6 + rand.Intn(5)), -1})
+	}
+	j := 0
+	tree.AscendEqual(tagged{5, 0}, func(i Item) bool {
+		if i != (tagged{5, j}) {
+			t.Fatalf("expected %v, got %v", tagged{5, j}, i)
+		}
+		j++
+		return true
+	})
+	if j != 1000 {
+		t.Errorf("expected 1000 equal items, got %d", j)
+	}
+	tree.AscendEqual(tagged{11, 0}, func(i Item) bool {
+		t.Errorf("unexpected item %v", i)
+		return true
+	})
+}
+
+func TestStrictResume(t *testing.T) {
+	tree := New(lessTagged)
+	for copy := 0; copy < 3; copy++ {
+		for _, k := range rand.Perm(30) {
+			tree.InsertNoReplace(tagged{Int(k), copy})
+		}
+	}
+	// Page through the keys ten items at a time, resuming after the last key
+	// seen. A page may end in the middle of a run of duplicates, whose rest is
+	// then skipped, but no key may be delivered by two pages.
+	pageOf := map[Int]int{}
+	var last Item = Inf(-1)
+	for page := 0; page < 100; page++ {
+		n := 0
+		tree.AscendGreaterThan(last, func(i Item) bool {
+			k := i.(tagged).key
+			if p, ok := pageOf[k]; ok && p != page {
+				t.Fatalf("key %d delivered by pages %d and %d", k, p, page)
+			}
+			pageOf[k] = page
+			last = i
+			n++
+			return n < 10
+		})
+		if n == 0 {
+			break
+		}
+	}
+	if len(pageOf) != 30 {
+		t.Errorf("expected all 30 keys to be delivered, got %d", len(pageOf))
+	}
+
+	// Resume downward, which must skip every copy of the last key.
+	var keys []Int
+	tree.DescendLessThan(tagged{10, 0}, func(i Item) bool {
+		keys = append(keys, i.(tagged).key)
+		return len(keys) < 4
+	})
+	expected := []Int{9, 9, 9, 8}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v but got %v", expected, keys)
+	}
+}
+
+func TestPull(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	next, stop := tree.Pull(Int(500))
+	for j := 500; j < n; j++ {
+		if i, ok := next(); !ok || i != Int(j) {
+			t.Fatalf("expected %d, got %v, %v", j, i, ok)
+		}
+	}
+	for k := 0; k < 2; k++ {
+		if i, ok := next(); ok || i != nil {
+			t.Fatalf("expected exhaustion, got %v, %v", i, ok)
+		}
+	}
+	stop()
+
+	next, stop = tree.Pull(Inf(-1))
+	if i, ok := next(); !ok || i != Int(0) {
+		t.Fatalf("expected 0, got %v, %v", i, ok)
+	}
+	stop()
+	stop()
+	if i, ok := next(); ok || i != nil {
+		t.Fatalf("expected nothing after stop, got %v, %v", i, ok)
+	}
+}
+
+func TestPullMemory(t *testing.T) {
+	allocs := func(n int) float64 {
+		tree := New(NaturalSortLessInt)
+		for i := 0; i < n; i++ {
+			tree.ReplaceOrInsert(Int(i))
+		}
+		return testing.AllocsPerRun(10, func() {
+			next, stop := tree.Pull(Inf(-1))
+			defer stop()
+			for _, ok := next(); ok; _, ok = next() {
+			}
+		})
+	}
+	if small, large := allocs(100), allocs(100000); large > small+5 {
+		t.Errorf("allocations grow with the tree: %.0f for 100 items, %.0f for 100000", small, large)
+	}
+}
+
+// TestConcurrentAscend is meant to be run with -race. Read-only traversals
+// may run concurrently, and must leave the tree modifiable once they end.
+func TestConcurrentAscend(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(1000) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				n := 0
+				tree.Ascend(func(Item) bool {
+					n++
+					return true
+				})
+				if n != 1000 {
+					t.Errorf("expected 1000 items, got %d", n)
+				}
+				tree.Descend(func(Item) bool { return true })
+			}
+		}()
+	}
+	wg.Wait()
+	tree.ReplaceOrInsert(Int(1000))
+	if tree.Len() != 1001 {
+		t.Errorf("expected len 1001, got %d", tree.Len())
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+// MergeJoin walks a and b together in ascending order, and calls fn for each
+// pair of items, one from each tree, that have the same order. Both trees must
+// be ordered the same way; the comparison function of a is used to compare
+// items across the trees. If a key appears several times in either tree, fn
+// is called for every combination of the duplicates (the cross product), in
+// the order the duplicates are stored. It will stop whenever fn returns false.
+// MergeJoin takes O(n+m) time, plus the number of calls to fn.
+func MergeJoin(a, b *LLRB, fn func(left, right Item) bool) {
+	ia, ib := a.NewIterator(), b.NewIterator()
+	okA, okB := ia.Next(), ib.Next()
+	var left, right []Item
+	for okA && okB {
+		x, y := ia.Item(), ib.Item()
+		switch c := a.compare(x, y); {
+		case c < 0:
+			okA = ia.Next()
+		case c > 0:
+			okB = ib.Next()
+		default:
+			left, right = left[:0], right[:0]
+			for okA && a.compare(ia.Item(), x) == 0 {
+				left = append(left, ia.Item())
+				okA = ia.Next()
+			}
+			for okB && a.compare(ib.Item(), x) == 0 {
+				right = append(right, ib.Item())
+				okB = ib.Next()
+			}
+			for _, l := range left {
+				for _, r := range right {
+					if !fn(l, r) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
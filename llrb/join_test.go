@@ -0,0 +1,50 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeJoin(t *testing.T) {
+	a, b := New(lessTagged), New(lessTagged)
+	for _, k := range []int{1, 2, 4, 6} {
+		a.InsertNoReplace(tagged{Int(k), 0})
+	}
+	a.InsertNoReplace(tagged{4, 1})
+	for _, k := range []int{0, 2, 3, 4, 6, 7} {
+		b.InsertNoReplace(tagged{Int(k), 10})
+	}
+	b.InsertNoReplace(tagged{4, 11})
+	var pairs [][2]Item
+	MergeJoin(a, b, func(l, r Item) bool {
+		pairs = append(pairs, [2]Item{l, r})
+		return true
+	})
+	expected := [][2]Item{
+		{tagged{2, 0}, tagged{2, 10}},
+		{tagged{4, 0}, tagged{4, 10}},
+		{tagged{4, 0}, tagged{4, 11}},
+		{tagged{4, 1}, tagged{4, 10}},
+		{tagged{4, 1}, tagged{4, 11}},
+		{tagged{6, 0}, tagged{6, 10}},
+	}
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Errorf("expected %v but got %v", expected, pairs)
+	}
+	k := 0
+	MergeJoin(a, b, func(l, r Item) bool {
+		k++
+		return k < 3
+	})
+	if k != 3 {
+		t.Errorf("expected the join to stop after 3 pairs, got %d", k)
+	}
+	MergeJoin(a, New(lessTagged), func(l, r Item) bool {
+		t.Errorf("unexpected pair %v, %v with an empty tree", l, r)
+		return true
+	})
+}
@@ -0,0 +1,34 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import "encoding/json"
+
+// MarshalJSON implements json.Marshaler. The tree is encoded as an array of
+// its items in ascending order.
+func (t *LLRB) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.ToSlice())
+}
+
+// UnmarshalJSONItems replaces the contents of the tree with the items of the
+// JSON array in data, using decode to decode each element. The array must be
+// in ascending order, as MarshalJSON writes it. It is not named UnmarshalJSON,
+// since the items' type is only known to decode. The tree is left unchanged if
+// an error is returned.
+func (t *LLRB) UnmarshalJSONItems(data []byte, decode func(json.RawMessage) (Item, error)) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	items := make([]Item, len(raw))
+	for i, r := range raw {
+		item, err := decode(r)
+		if err != nil {
+			return err
+		}
+		items[i] = item
+	}
+	return t.load(items)
+}
@@ -0,0 +1,44 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var ints []int
+	if err := json.Unmarshal(data, &ints); err != nil {
+		t.Fatalf("output is not an array of numbers: %v", err)
+	}
+	if len(ints) != 100 || !sort.IntsAreSorted(ints) {
+		t.Errorf("expected 100 sorted items, got %v", ints)
+	}
+
+	decoded := New(NaturalSortLessInt)
+	err = decoded.UnmarshalJSONItems(data, func(r json.RawMessage) (Item, error) {
+		var i int
+		err := json.Unmarshal(r, &i)
+		return Int(i), err
+	})
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.Len() != tree.Len() {
+		t.Errorf("expected len %d, got %d", tree.Len(), decoded.Len())
+	}
+	checkInvariants(t, decoded)
+}
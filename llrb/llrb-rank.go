@@ -0,0 +1,162 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+// Rank returns the number of items in the tree that are strictly less than key.
+func (t *LLRB) Rank(key Item) int {
+	rank := 0
+	h := t.root
+	for h != nil {
+		if less(t.comp, h.Item, key) {
+			rank += size(h.Left) + 1
+			h = h.Right
+		} else {
+			h = h.Left
+		}
+	}
+	return rank
+}
+
+// rankAfter returns the number of items in the tree that are less or equal
+// to key.
+func (t *LLRB) rankAfter(key Item) int {
+	rank := 0
+	h := t.root
+	for h != nil {
+		if less(t.comp, key, h.Item) {
+			h = h.Left
+		} else {
+			rank += size(h.Left) + 1
+			h = h.Right
+		}
+	}
+	return rank
+}
+
+// Count returns the number of items in the tree that have the same order as
+// key, in O(log n) time.
+func (t *LLRB) Count(key Item) int {
+	return t.rankAfter(key) - t.Rank(key)
+}
+
+// CountRange returns the number of items in the tree that are greater or
+// equal to greaterOrEqual and less than lessThan, in O(log n) time.
+func (t *LLRB) CountRange(greaterOrEqual, lessThan Item) int {
+	n := t.Rank(lessThan) - t.Rank(greaterOrEqual)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Select returns the k-th smallest item in the tree, counting from 0.
+// It returns nil if k is out of range.
+func (t *LLRB) Select(k int) Item {
+	item, _ := t.GetByRank(k)
+	return item
+}
+
+// GetByRank is like Select, but also reports whether k is in range.
+func (t *LLRB) GetByRank(k int) (Item, bool) {
+	if k < 0 || k >= t.count {
+		return nil, false
+	}
+	h := t.root
+	for h != nil {
+		l := size(h.Left)
+		switch {
+		case k < l:
+			h = h.Left
+		case k > l:
+			k -= l + 1
+			h = h.Right
+		default:
+			return h.Item, true
+		}
+	}
+	return nil, false
+}
+
+// Quantile returns the item of rank floor(q*(n-1)) among the n items in the
+// tree, so that 0 gives the minimum and 1 the maximum. Items are not
+// interpolated: it always returns a stored item, or nil if the tree is empty.
+// It panics if q is not in [0, 1].
+func (t *LLRB) Quantile(q float64) Item {
+	if !(q >= 0 && q <= 1) {
+		panic("llrb: quantile out of range")
+	}
+	if t.count == 0 {
+		return nil
+	}
+	return t.Select(int(q * float64(t.count-1)))
+}
+
+// Median returns the middle item of the tree, or the lower of the two middle
+// items if there is an even number of them. It returns nil if the tree is
+// empty.
+func (t *LLRB) Median() Item {
+	return t.Quantile(0.5)
+}
+
+// AscendPage skips the first offset elements that are greater or equal to
+// pivot, then calls fn for at most limit of the following elements, in
+// ascending order. It stops early whenever fn returns false, and returns the
+// number of elements delivered to fn. The skip takes O(log n) time.
+func (t *LLRB) AscendPage(pivot Item, offset, limit int, fn ItemIterator) int {
+	if offset < 0 || limit < 0 {
+		panic("llrb: negative offset or limit")
+	}
+	if limit == 0 {
+		return 0
+	}
+	defer t.walk()()
+	n := 0
+	t.ascendFrom(t.root, t.Rank(pivot)+offset, func(i Item) bool {
+		n++
+		return fn(i) && n < limit
+	})
+	return n
+}
+
+// ascendFrom visits the elements below h in ascending order, starting with
+// the k-th smallest of them.
+func (t *LLRB) ascendFrom(h *Node, k int, iterator ItemIterator) bool {
+	if h == nil {
+		return true
+	}
+	l := size(h.Left)
+	if k > l {
+		return t.ascendFrom(h.Right, k-l-1, iterator)
+	}
+	if !t.ascendFrom(h.Left, k, iterator) {
+		return false
+	}
+	if !iterator(h.Item) {
+		return false
+	}
+	return t.ascendFrom(h.Right, 0, iterator)
+}
+
+// SplitIntoRanges returns k-1 keys that split the items of the tree into k
+// ranges of nearly equal size: the items less than the first key, those from
+// each key up to the next, and those greater or equal to the last key. It
+// returns every item if k exceeds Len, and nil for an empty tree. It takes
+// O(k log n) time.
+func (t *LLRB) SplitIntoRanges(k int) []Item {
+	if k < 1 {
+		panic("llrb: k must be positive")
+	}
+	if t.count == 0 {
+		return nil
+	}
+	if k > t.count {
+		return t.ToSlice()
+	}
+	keys := make([]Item, 0, k-1)
+	for i := 1; i < k; i++ {
+		keys = append(keys, t.Select(i*t.count/k))
+	}
+	return keys
+}
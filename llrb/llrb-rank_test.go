@@ -0,0 +1,343 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// checkSizes verifies the subtree size of every node below h.
+func checkSizes(t *testing.T, h *Node) int {
+	if h == nil {
+		return 0
+	}
+	n := 1 + checkSizes(t, h.Left) + checkSizes(t, h.Right)
+	if h.size != n {
+		t.Fatalf("node %v: size %d, expected %d", h.Item, h.size, n)
+	}
+	return n
+}
+
+func TestRankSelect(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	checkSizes(t, tree.Root())
+	for i := 0; i < n; i++ {
+		if r := tree.Rank(Int(i)); r != i {
+			t.Errorf("Rank(%d) = %d", i, r)
+		}
+		if s := tree.Select(i); s != Int(i) {
+			t.Errorf("Select(%d) = %v", i, s)
+		}
+	}
+	if r := tree.Rank(Int(n + 10)); r != n {
+		t.Errorf("Rank above max = %d, expected %d", r, n)
+	}
+	if tree.Select(-1) != nil || tree.Select(n) != nil {
+		t.Errorf("expected nil for out of range Select")
+	}
+}
+
+func TestRankSelectAfterDelete(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	// Delete every odd key, exercising the moveRed* and fixUp paths.
+	for _, i := range rand.Perm(n) {
+		if i%2 == 1 {
+			tree.Delete(Int(i))
+		}
+	}
+	tree.DeleteMin()
+	tree.DeleteMax()
+	checkSizes(t, tree.Root())
+	// Remaining keys are 2, 4, ..., n-4.
+	for k := 0; k < tree.Len(); k++ {
+		want := Int(2 * (k + 1))
+		if s := tree.Select(k); s != want {
+			t.Errorf("Select(%d) = %v, expected %v", k, s, want)
+		}
+		if r := tree.Rank(want); r != k {
+			t.Errorf("Rank(%v) = %d, expected %d", want, r, k)
+		}
+	}
+}
+
+func TestRankRandomOps(t *testing.T) {
+	for _, mode := range []Mode{Mode23, Mode234} {
+		tree := NewWithMode(NaturalSortLessInt, mode)
+		present := map[int]bool{}
+		for i := 0; i < 3000; i++ {
+			k := rand.Intn(200)
+			switch rand.Intn(6) {
+			case 0:
+				if m := tree.DeleteMin(); m != nil {
+					delete(present, int(m.(Int)))
+				}
+			case 1:
+				if m := tree.DeleteMax(); m != nil {
+					delete(present, int(m.(Int)))
+				}
+			case 2, 3:
+				tree.Delete(Int(k))
+				delete(present, k)
+			default:
+				tree.ReplaceOrInsert(Int(k))
+				present[k] = true
+			}
+			checkSizes(t, tree.Root())
+			less := 0
+			for j := range present {
+				if j < k {
+					less++
+				}
+			}
+			if r := tree.Rank(Int(k)); r != less {
+				t.Fatalf("mode %d: Rank(%d) = %d, expected %d", mode, k, r, less)
+			}
+		}
+		checkInvariants(t, tree)
+	}
+}
+
+func TestGetByRank(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if i, ok := tree.GetByRank(0); ok || i != nil {
+		t.Errorf("GetByRank(0) of an empty tree = %v, %v", i, ok)
+	}
+	present := map[int]bool{}
+	for op := 1; op <= 20000; op++ {
+		switch k := rand.Intn(2000); rand.Intn(5) {
+		case 0:
+			if m := tree.DeleteMin(); m != nil {
+				delete(present, int(m.(Int)))
+			}
+		case 1:
+			if m := tree.DeleteMax(); m != nil {
+				delete(present, int(m.(Int)))
+			}
+		case 2:
+			tree.Delete(Int(k))
+			delete(present, k)
+		default:
+			tree.ReplaceOrInsert(Int(k))
+			present[k] = true
+		}
+		if op%1000 != 0 {
+			continue
+		}
+		sorted := make([]int, 0, len(present))
+		for k := range present {
+			sorted = append(sorted, k)
+		}
+		sort.Ints(sorted)
+		for r, k := range sorted {
+			if i, ok := tree.GetByRank(r); !ok || i != Int(k) {
+				t.Fatalf("after %d ops: GetByRank(%d) = %v, %v, expected %d", op, r, i, ok, k)
+			}
+		}
+		if _, ok := tree.GetByRank(len(sorted)); ok {
+			t.Fatalf("after %d ops: GetByRank(%d) is in range", op, len(sorted))
+		}
+		if _, ok := tree.GetByRank(-1); ok {
+			t.Fatalf("GetByRank(-1) is in range")
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if tree.Median() != nil || tree.Quantile(0.99) != nil {
+		t.Errorf("expected nil for an empty tree")
+	}
+	tree.ReplaceOrInsert(Int(7))
+	if tree.Median() != Int(7) || tree.Quantile(0) != Int(7) || tree.Quantile(1) != Int(7) {
+		t.Errorf("expected the only item for every quantile")
+	}
+	tree.ReplaceOrInsert(Int(8))
+	if m := tree.Median(); m != Int(7) {
+		t.Errorf("expected the lower middle item, got %v", m)
+	}
+	if q := tree.Quantile(0.99); q != Int(7) {
+		t.Errorf("expected Quantile(0.99) to round down to 7, got %v", q)
+	}
+	if q := tree.Quantile(1); q != Int(8) {
+		t.Errorf("expected Quantile(1) = 8, got %v", q)
+	}
+
+	tree = New(NaturalSortLessInt)
+	for _, i := range rand.Perm(101) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	for _, c := range []struct {
+		q    float64
+		want Int
+	}{{0, 0}, {0.5, 50}, {0.9, 90}, {0.99, 99}, {0.995, 99}, {1, 100}} {
+		if got := tree.Quantile(c.q); got != c.want {
+			t.Errorf("Quantile(%v) = %v, expected %v", c.q, got, c.want)
+		}
+	}
+	for _, q := range []float64{-0.1, 1.1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected Quantile(%v) to panic", q)
+				}
+			}()
+			tree.Quantile(q)
+		}()
+	}
+}
+
+func TestCount(t *testing.T) {
+	// A stable tree deletes exactly one of several equal items.
+	tree := NewStable(lessTagged)
+	oracle := map[int]int{}
+	for i := 0; i < 5000; i++ {
+		k := rand.Intn(50)
+		if rand.Intn(4) == 0 {
+			if tree.Delete(tagged{Int(k), 0}) != nil {
+				oracle[k]--
+			}
+		} else {
+			tree.InsertNoReplace(tagged{Int(k), i})
+			oracle[k]++
+		}
+	}
+	checkSizes(t, tree.Root())
+	for k := -1; k <= 50; k++ {
+		if c := tree.Count(tagged{Int(k), 0}); c != oracle[k] {
+			t.Errorf("Count(%d) = %d, expected %d", k, c, oracle[k])
+		}
+	}
+}
+
+func TestAscendPage(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	page := func(pivot Item, offset, limit int) []Item {
+		var ary []Item
+		n := tree.AscendPage(pivot, offset, limit, func(i Item) bool {
+			ary = append(ary, i)
+			return true
+		})
+		if n != len(ary) {
+			t.Errorf("AscendPage returned %d, delivered %d", n, len(ary))
+		}
+		return ary
+	}
+	expected := []Item{Int(25), Int(26), Int(27)}
+	if ary := page(Int(20), 5, 3); !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	expected = []Item{Int(98), Int(99)}
+	if ary := page(Int(90), 8, 10); !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	if ary := page(Int(90), 10, 10); len(ary) != 0 {
+		t.Errorf("expected an empty page past the end, got %v", ary)
+	}
+	if ary := page(Inf(-1), 0, 0); len(ary) != 0 {
+		t.Errorf("expected an empty page for limit 0, got %v", ary)
+	}
+	if n := tree.AscendPage(Inf(-1), 0, 10, func(Item) bool { return false }); n != 1 {
+		t.Errorf("expected to stop after 1 item, got %d", n)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for a negative offset")
+		}
+	}()
+	page(Inf(-1), -1, 10)
+}
+
+func TestSplitIntoRanges(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if tree.SplitIntoRanges(4) != nil {
+		t.Errorf("expected no keys for an empty tree")
+	}
+	tree.ReplaceOrInsertBulk(Int(1), Int(2), Int(3))
+	if keys := tree.SplitIntoRanges(4); len(keys) != 3 {
+		t.Errorf("expected every key when k exceeds Len, got %v", keys)
+	}
+
+	n := 1000000
+	if testing.Short() {
+		n = 10000
+	}
+	tree = New(NaturalSortLessInt)
+	for i := 0; i < n; i++ {
+		tree.ReplaceOrInsert(Int(rand.Int()))
+	}
+	for _, k := range []int{1, 2, 7, 64} {
+		keys := tree.SplitIntoRanges(k)
+		if len(keys) != k-1 {
+			t.Fatalf("k=%d: got %d keys", k, len(keys))
+		}
+		bounds := append([]Item{Inf(-1)}, append(keys, Inf(1))...)
+		min, max := n, 0
+		for i := 0; i < k; i++ {
+			size := tree.Rank(bounds[i+1]) - tree.Rank(bounds[i])
+			if size < min {
+				min = size
+			}
+			if size > max {
+				max = size
+			}
+		}
+		if max-min > 1 {
+			t.Errorf("k=%d: range sizes vary from %d to %d", k, min, max)
+		}
+	}
+}
+
+func TestCountRange(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	var items []int
+	for i := 0; i < 2000; i++ {
+		k := rand.Intn(500)
+		items = append(items, k)
+		tree.InsertNoReplace(Int(k))
+	}
+	count := func(lo, hi Item) int {
+		n := 0
+		for _, k := range items {
+			if !less(tree.comp, Int(k), lo) && less(tree.comp, Int(k), hi) {
+				n++
+			}
+		}
+		return n
+	}
+	bounds := []Item{Inf(-1), Int(-1), Int(0), Int(250), Int(499), Int(500), Inf(1)}
+	for i := 0; i < 100; i++ {
+		bounds = append(bounds, Int(rand.Intn(520)-10))
+	}
+	for _, lo := range bounds {
+		for _, hi := range bounds {
+			if n, want := tree.CountRange(lo, hi), count(lo, hi); n != want {
+				t.Fatalf("CountRange(%v, %v) = %d, expected %d", lo, hi, n, want)
+			}
+			visited := 0
+			tree.AscendRange(lo, hi, func(Item) bool {
+				visited++
+				return true
+			})
+			if n := tree.CountRange(lo, hi); n != visited {
+				t.Fatalf("CountRange(%v, %v) = %d, AscendRange visited %d", lo, hi, n, visited)
+			}
+		}
+	}
+}
@@ -4,6 +4,8 @@
 
 package llrb
 
+import "unsafe"
+
 // GetHeight() returns an item in the tree with key @key, and it's height in the tree
 func (t *LLRB) GetHeight(key Item) (result Item, depth int) {
 	return t.getHeight(t.root, key)
@@ -24,6 +26,28 @@ func (t *LLRB) getHeight(h *Node, item Item) (Item, int) {
 	return h.Item, 0
 }
 
+// GetCounted is like Get, but also returns the number of times the tree's
+// comparison function was called, for profiling comparers and tree shapes. It
+// is at most twice the height of the tree, or the height for a tree made by
+// NewCmp.
+func (t *LLRB) GetCounted(key Item) (Item, int) {
+	calls := 0
+	comp, cmp := t.comp, t.cmp
+	counted := &LLRB{root: t.root}
+	counted.comp = func(a, b interface{}) bool {
+		calls++
+		return comp(a, b)
+	}
+	if cmp != nil {
+		counted.cmp = func(a, b interface{}) int {
+			calls++
+			return cmp(a, b)
+		}
+	}
+	item, _ := counted.Get2(key)
+	return item, calls
+}
+
 // HeightStats() returns the average and standard deviation of the height
 // of elements in the tree
 func (t *LLRB) HeightStats() (avg, stddev float64) {
@@ -44,3 +68,41 @@ func heightStats(h *Node, d int, av *avgVar) {
 		heightStats(h.Right, d+1, av)
 	}
 }
+
+// Height returns the number of nodes on the longest path from the root to a
+// leaf. It is zero for an empty tree and at most 2*log2(n+1) otherwise.
+func (t *LLRB) Height() int { return height(t.root) }
+
+func height(h *Node) int {
+	if h == nil {
+		return 0
+	}
+	l, r := height(h.Left), height(h.Right)
+	if l > r {
+		return l + 1
+	}
+	return r + 1
+}
+
+// BlackHeight returns the number of black nodes on the leftmost path from the
+// root to a leaf. In a valid tree, every such path has the same number.
+func (t *LLRB) BlackHeight() int {
+	n := 0
+	for h := t.root; h != nil; h = h.Left {
+		if h.Black {
+			n++
+		}
+	}
+	return n
+}
+
+// MemStats returns the number of nodes held by the tree, including those kept
+// for reuse by a pooled tree, and an estimate of the bytes they take. The
+// estimate leaves out the items themselves, whose size is not known.
+func (t *LLRB) MemStats() (nodes int, approxBytes int) {
+	nodes = t.count
+	for h := t.free; h != nil; h = h.Right {
+		nodes++
+	}
+	return nodes, nodes * int(unsafe.Sizeof(Node{}))
+}
@@ -17,16 +17,30 @@
 package llrb
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"runtime/debug"
+	"strings"
+	"sync/atomic"
 )
 
 // Tree is a Left-Leaning Red-Black (LLRB) implementation of 2-3 trees
 type LLRB struct {
-	count int
-	root  *Node
-	comp  Comparer
+	count      int
+	root       *Node
+	comp       Comparer
+	cmp        CmpFunc // If set, used in place of comp on the hot paths
+	strict     bool
+	walking    int32  // Number of callback traversals in progress, updated atomically
+	mods       uint64 // Bumped by every modification of the tree
+	noModCheck bool   // If set, modifications during iteration are not detected
+	mode       Mode
+	stable     bool   // If set, equal items are ordered by insertion
+	seq        uint64 // Sequence number of the last node inserted into a stable tree
+	pool       bool   // If set, deleted nodes are kept in free for reuse
+	free       *Node  // Deleted nodes, linked by their Right pointers
+	owner      *owner // Nodes with a different owner are shared with a snapshot
 }
 
 type Node struct {
@@ -34,13 +48,55 @@ type Node struct {
 	Left, Right *Node // Pointers to left and right child nodes
 	Black       bool  // If set, the color of the link (incoming from the parent) is black
 	// In the LLRB, new nodes are always red, hence the zero-value for node
+	size  int    // Number of nodes in the subtree rooted at this node
+	seq   uint64 // Insertion sequence number of Item, in stable trees
+	owner *owner // The tree that may modify this node in place
 }
 
+// IsBlack reports whether the link to h from its parent is black. The missing
+// children of a leaf, represented by a nil *Node, are black.
+func (h *Node) IsBlack() bool { return !isRed(h) }
+
+// LeftChild returns the left child of h, or nil if h is nil or has none.
+func (h *Node) LeftChild() *Node {
+	if h == nil {
+		return nil
+	}
+	return h.Left
+}
+
+// RightChild returns the right child of h, or nil if h is nil or has none.
+func (h *Node) RightChild() *Node {
+	if h == nil {
+		return nil
+	}
+	return h.Right
+}
+
+// owner identifies the nodes that a tree may modify in place. It is not
+// zero-sized, so that distinct owners have distinct addresses.
+type owner struct{ _ byte }
+
 type Item interface {
 }
 
 type Comparer func(a, b interface{}) bool
 
+// Mode selects the kind of balanced tree that an LLRB implements.
+type Mode int
+
+const (
+	// Mode23 implements 2-3 trees, splitting 4-nodes on the way up from an insert.
+	Mode23 Mode = iota
+	// Mode234 implements 2-3-4 trees, splitting 4-nodes on the way down to an
+	// insert, which leaves more 4-nodes in the tree and rotates less often.
+	Mode234
+)
+
+// CmpFunc is a three-valued comparison function. It returns a negative number
+// if a < b, zero if a and b have the same order, and a positive number if a > b.
+type CmpFunc func(a, b interface{}) int
+
 // Return true if x < y according to the custom comparison function.
 func less(comp Comparer, x, y Item) bool {
 	if x == pinf || y == ninf {
@@ -52,6 +108,38 @@ func less(comp Comparer, x, y Item) bool {
 	return comp(x, y)
 }
 
+// compare returns the three-valued order of x and y. It calls the tree's
+// CmpFunc once, if it has one, and otherwise falls back to calling comp twice.
+func (t *LLRB) compare(x, y Item) int {
+	if t.cmp != nil && !isInf(x) && !isInf(y) {
+		return t.cmp(x, y)
+	}
+	switch {
+	case less(t.comp, x, y):
+		return -1
+	case less(t.comp, y, x):
+		return 1
+	}
+	return 0
+}
+
+// compareNode is like compare, but in stable trees it breaks ties between
+// item and h.Item by their sequence numbers. A zero seq breaks no ties.
+func (t *LLRB) compareNode(item Item, seq uint64, h *Node) int {
+	c := t.compare(item, h.Item)
+	if c == 0 && seq != 0 {
+		switch {
+		case seq < h.seq:
+			return -1
+		case seq > h.seq:
+			return 1
+		}
+	}
+	return c
+}
+
+func isInf(x Item) bool { return x == pinf || x == ninf }
+
 // Inf returns an Item that is "bigger than" any other item, if sign is positive.
 // Otherwise  it returns an Item that is "smaller than" any other item.
 func Inf(sign int) Item {
@@ -88,10 +176,110 @@ func New(sortFunction Comparer) *LLRB {
 	return ret
 }
 
+// NewStable allocates a new tree that keeps items of the same order in the
+// order they were inserted. Traversals visit them oldest first, and Delete
+// removes the oldest of them.
+func NewStable(sortFunction Comparer) *LLRB {
+	ret := New(sortFunction)
+	ret.stable = true
+	return ret
+}
+
+// NewPooled allocates a new tree that keeps the nodes of deleted items and
+// reuses them for later inserts, which cuts allocations under churn. Nodes
+// that are obtained from the tree, as by Root, must then not be retained after
+// the tree is modified.
+func NewPooled(sortFunction Comparer) *LLRB {
+	ret := New(sortFunction)
+	ret.pool = true
+	return ret
+}
+
+// walk marks the start of a callback traversal and returns the function
+// that marks its end. The count is atomic, as read-only traversals may run
+// concurrently.
+func (t *LLRB) walk() func() {
+	atomic.AddInt32(&t.walking, 1)
+	return func() { atomic.AddInt32(&t.walking, -1) }
+}
+
+// mutate records a modification of the tree. It panics if the tree is
+// modified from inside a traversal callback.
+func (t *LLRB) mutate() {
+	if atomic.LoadInt32(&t.walking) > 0 && !t.noModCheck {
+		panic("llrb: tree modified during traversal")
+	}
+	t.mods++
+}
+
+// NewWithMode allocates a new tree implementing either 2-3 or 2-3-4 trees.
+// Trees allocated by New use Mode23.
+func NewWithMode(sortFunction Comparer, mode Mode) *LLRB {
+	ret := New(sortFunction)
+	ret.mode = mode
+	return ret
+}
+
+// NewCmp allocates a new tree ordered by a three-valued comparison function.
+// Lookups, inserts and deletes then make a single comparison per node visited.
+func NewCmp(cmp CmpFunc) *LLRB {
+	ret := New(func(a, b interface{}) bool { return cmp(a, b) < 0 })
+	ret.cmp = cmp
+	return ret
+}
+
+// SetStrict toggles a debug check that a node and its children are present
+// before flip recolors them. When enabled, a missing node panics with a
+// message holding the whole tree, rather than with a nil pointer dereference.
+// Nothing is printed. The check is off by default.
+func (t *LLRB) SetStrict(strict bool) {
+	t.strict = strict
+}
+
+// SetModCheck toggles the detection of modifications made while the tree is
+// being iterated, whether from inside a traversal callback or between calls
+// to an Iterator. A detected modification panics. The check is on by default;
+// turning it off saves a little time in performance-critical code.
+func (t *LLRB) SetModCheck(check bool) {
+	t.noModCheck = !check
+}
+
+// Clear removes all items from the tree, keeping its settings. It takes O(1)
+// time, or O(n) in a pooled tree, whose nodes are kept for reuse.
+func (t *LLRB) Clear() {
+	t.mutate()
+	if t.pool {
+		t.releaseAll(t.root)
+	}
+	t.root, t.count = nil, 0
+}
+
+// releaseAll releases every node below h. Nodes shared with a snapshot only
+// have shared nodes below them, and are skipped.
+func (t *LLRB) releaseAll(h *Node) {
+	if h == nil || h.owner != t.owner {
+		return
+	}
+	t.releaseAll(h.Left)
+	t.releaseAll(h.Right)
+	t.release(h)
+}
+
 // SetRoot sets the root node of the tree.
 // It is intended to be used by functions that deserialize the tree.
 func (t *LLRB) SetRoot(r *Node) {
+	t.mutate()
 	t.root = r
+	t.count = setSizes(r)
+}
+
+// setSizes recomputes the subtree sizes below h and returns the size of h.
+func setSizes(h *Node) int {
+	if h == nil {
+		return 0
+	}
+	h.size = 1 + setSizes(h.Left) + setSizes(h.Right)
+	return h.size
 }
 
 // Root returns the root node of the tree.
@@ -100,6 +288,67 @@ func (t *LLRB) Root() *Node {
 	return t.root
 }
 
+// Clone returns an independent copy of the tree. The nodes are copied, while
+// the items themselves are shared between the two trees.
+func (t *LLRB) Clone() *LLRB {
+	ret := t.newLike()
+	ret.count = t.count
+	ret.seq = t.seq
+	ret.root = cloneNode(t.root)
+	return ret
+}
+
+// newLike returns an empty tree with the same settings as t.
+func (t *LLRB) newLike() *LLRB {
+	ret := New(t.comp)
+	ret.cmp = t.cmp
+	ret.strict = t.strict
+	ret.noModCheck = t.noModCheck
+	ret.mode = t.mode
+	ret.stable = t.stable
+	ret.pool = t.pool
+	return ret
+}
+
+func cloneNode(h *Node) *Node {
+	if h == nil {
+		return nil
+	}
+	c := *h
+	c.owner = nil
+	c.Left = cloneNode(h.Left)
+	c.Right = cloneNode(h.Right)
+	return &c
+}
+
+// Snapshot returns a copy of the tree in O(1) time. The two trees share their
+// nodes until either is modified, at which point the modified tree copies the
+// O(log n) nodes on the path it touches (copy-on-write). Shared nodes are
+// never modified, so a snapshot can be read by one goroutine while the
+// original is modified by another. The tradeoff is memory: every node carries
+// an owner pointer, and nodes stay alive for as long as any snapshot refers
+// to them.
+func (t *LLRB) Snapshot() *LLRB {
+	ret := t.newLike()
+	ret.count = t.count
+	ret.seq = t.seq
+	ret.root = t.root
+	ret.owner = &owner{}
+	t.owner = &owner{}
+	return ret
+}
+
+// mutable returns a node that t may modify in place and that holds the same
+// contents as h, copying h if it is shared with a snapshot.
+func (t *LLRB) mutable(h *Node) *Node {
+	if h == nil || h.owner == t.owner {
+		return h
+	}
+	c := *h
+	c.owner = t.owner
+	return &c
+}
+
 // Len returns the number of nodes in the tree.
 func (t *LLRB) Len() int { return t.count }
 
@@ -110,50 +359,259 @@ func (t *LLRB) Has(key Item) bool {
 
 // Get retrieves an element from the tree whose order is the same as that of key.
 func (t *LLRB) Get(key Item) Item {
-	h := t.root
+	item, _ := t.Get2(key)
+	return item
+}
+
+// Get2 is like Get, but also reports whether the element was found, which
+// tells a missing element apart from a stored item that is a typed nil.
+func (t *LLRB) Get2(key Item) (Item, bool) {
+	if h := t.GetNode(key); h != nil {
+		return h.Item, true
+	}
+	return nil, false
+}
+
+// GetNode returns the node holding an element whose order is the same as
+// that of key, or nil if there is none. The node belongs to the tree: it may
+// be read, but changing its links, color or order corrupts the tree.
+func (t *LLRB) GetNode(key Item) *Node {
+	return t.find(t.root, key)
+}
+
+// find returns the first node below h, in the order of a search from h, whose
+// element has the same order as key.
+func (t *LLRB) find(h *Node, key Item) *Node {
 	for h != nil {
+		c := t.compare(key, h.Item)
 		switch {
-		case less(t.comp, key, h.Item):
+		case c < 0:
 			h = h.Left
-		case less(t.comp, h.Item, key):
+		case c > 0:
 			h = h.Right
 		default:
-			return h.Item
+			return h
 		}
 	}
 	return nil
 }
 
+// GetAll returns every element in the tree whose order is the same as that of
+// key, in the order they are stored.
+func (t *LLRB) GetAll(key Item) []Item {
+	return t.AppendAll(nil, key)
+}
+
+// AppendAll is like GetAll, but appends the elements to dst and returns the
+// extended slice.
+func (t *LLRB) AppendAll(dst []Item, key Item) []Item {
+	t.AscendEqual(key, func(i Item) bool {
+		dst = append(dst, i)
+		return true
+	})
+	return dst
+}
+
 // Min returns the minimum element in the tree.
 func (t *LLRB) Min() Item {
+	item, _ := t.Min2()
+	return item
+}
+
+// Min2 is like Min, but also reports whether the tree has an element.
+func (t *LLRB) Min2() (Item, bool) {
 	h := t.root
 	if h == nil {
-		return nil
+		return nil, false
 	}
 	for h.Left != nil {
 		h = h.Left
 	}
-	return h.Item
+	return h.Item, true
 }
 
 // Max returns the maximum element in the tree.
 func (t *LLRB) Max() Item {
+	item, _ := t.Max2()
+	return item
+}
+
+// Max2 is like Max, but also reports whether the tree has an element.
+func (t *LLRB) Max2() (Item, bool) {
 	h := t.root
 	if h == nil {
-		return nil
+		return nil, false
 	}
 	for h.Right != nil {
 		h = h.Right
 	}
-	return h.Item
+	return h.Item, true
+}
+
+// MinOK is the same as Min2.
+func (t *LLRB) MinOK() (Item, bool) { return t.Min2() }
+
+// MaxOK is the same as Max2.
+func (t *LLRB) MaxOK() (Item, bool) { return t.Max2() }
+
+// Floor returns the largest element in the tree that is less than or equal
+// to key, or nil if there is no such element. Of several such elements of the
+// same order, it returns the last in ascending order.
+func (t *LLRB) Floor(key Item) Item {
+	floor, _ := t.FloorOK(key)
+	return floor
+}
+
+// FloorOK is like Floor, but also reports whether the element returned has
+// the same order as key, rather than being strictly less than it.
+func (t *LLRB) FloorOK(key Item) (Item, bool) {
+	var floor Item
+	exact := false
+	h := t.root
+	for h != nil {
+		if less(t.comp, key, h.Item) {
+			h = h.Left
+		} else {
+			floor = h.Item
+			exact = !less(t.comp, h.Item, key)
+			h = h.Right
+		}
+	}
+	return floor, exact
+}
+
+// Ceiling returns the smallest element in the tree that is greater than or
+// equal to key, or nil if there is no such element. Of several such elements
+// of the same order, it returns the first in ascending order.
+func (t *LLRB) Ceiling(key Item) Item {
+	ceiling, _ := t.CeilingOK(key)
+	return ceiling
+}
+
+// CeilingOK is like Ceiling, but also reports whether the element returned
+// has the same order as key, rather than being strictly greater than it.
+func (t *LLRB) CeilingOK(key Item) (Item, bool) {
+	var ceiling Item
+	exact := false
+	h := t.root
+	for h != nil {
+		if less(t.comp, h.Item, key) {
+			h = h.Right
+		} else {
+			ceiling = h.Item
+			exact = !less(t.comp, key, h.Item)
+			h = h.Left
+		}
+	}
+	return ceiling, exact
+}
+
+// FindFirst returns the smallest element in the tree for which pred returns
+// true, or nil if there is none. pred must be monotonic: false for the
+// elements up to some point in ascending order, and true for all the elements
+// after it. FindFirst then calls it O(log n) times; for any other pred, the
+// result is unspecified.
+func (t *LLRB) FindFirst(pred func(Item) bool) Item {
+	var first Item
+	h := t.root
+	for h != nil {
+		if pred(h.Item) {
+			first = h.Item
+			h = h.Left
+		} else {
+			h = h.Right
+		}
+	}
+	return first
+}
+
+// Nearest returns the element in the tree closest to key. It finds in one
+// descent the largest element less than key and the smallest element greater
+// than key, and returns the one chosen by closer, which is called only if both
+// exist. An element with the same order as key is returned as is. Nearest
+// returns nil for an empty tree.
+func (t *LLRB) Nearest(key Item, closer func(a, b, key Item) Item) Item {
+	var lower, higher Item
+	h := t.root
+	for h != nil {
+		c := t.compare(key, h.Item)
+		switch {
+		case c < 0:
+			higher = h.Item
+			h = h.Left
+		case c > 0:
+			lower = h.Item
+			h = h.Right
+		default:
+			return h.Item
+		}
+	}
+	if lower == nil {
+		return higher
+	}
+	if higher == nil {
+		return lower
+	}
+	return closer(lower, higher, key)
+}
+
+// Successor returns the smallest element in the tree that is strictly
+// greater than key, or nil if there is no such element.
+func (t *LLRB) Successor(key Item) Item {
+	var succ Item
+	h := t.root
+	for h != nil {
+		if less(t.comp, key, h.Item) {
+			succ = h.Item
+			h = h.Left
+		} else {
+			h = h.Right
+		}
+	}
+	return succ
+}
+
+// Predecessor returns the largest element in the tree that is strictly
+// less than key, or nil if there is no such element.
+func (t *LLRB) Predecessor(key Item) Item {
+	var pred Item
+	h := t.root
+	for h != nil {
+		if less(t.comp, h.Item, key) {
+			pred = h.Item
+			h = h.Right
+		} else {
+			h = h.Left
+		}
+	}
+	return pred
 }
 
+// Lower returns the largest item strictly less than key, skipping duplicates;
+// it is an alias of Predecessor.
+func (t *LLRB) Lower(key Item) Item { return t.Predecessor(key) }
+
+// Higher returns the smallest item strictly greater than key, skipping
+// duplicates; it is an alias of Successor.
+func (t *LLRB) Higher(key Item) Item { return t.Successor(key) }
+
 func (t *LLRB) ReplaceOrInsertBulk(items ...Item) {
 	for _, i := range items {
 		t.ReplaceOrInsert(i)
 	}
 }
 
+// ReplaceOrInsertBulkStatus is like ReplaceOrInsertBulk, but returns for each
+// of items the element it replaced, or nil if it was inserted. An item may
+// replace an earlier one of the same call.
+func (t *LLRB) ReplaceOrInsertBulkStatus(items ...Item) []Item {
+	replaced := make([]Item, len(items))
+	for j, i := range items {
+		replaced[j] = t.ReplaceOrInsert(i)
+	}
+	return replaced
+}
+
 func (t *LLRB) InsertNoReplaceBulk(items ...Item) {
 	for _, i := range items {
 		t.InsertNoReplace(i)
@@ -163,6 +621,7 @@ func (t *LLRB) InsertNoReplaceBulk(items ...Item) {
 // ReplaceOrInsert inserts item into the tree. If an existing
 // element has the same order, it is removed from the tree and returned.
 func (t *LLRB) ReplaceOrInsert(item Item) Item {
+	t.mutate()
 	if item == nil {
 		panic("inserting nil item")
 	}
@@ -175,23 +634,48 @@ func (t *LLRB) ReplaceOrInsert(item Item) Item {
 	return replaced
 }
 
+// ErrNilItem is returned by TryReplaceOrInsert for a nil item.
+var ErrNilItem = errors.New("llrb: inserting nil item")
+
+// TryReplaceOrInsert is like ReplaceOrInsert, but returns ErrNilItem and
+// leaves the tree unchanged if item is nil, instead of panicking.
+func (t *LLRB) TryReplaceOrInsert(item Item) (Item, error) {
+	if item == nil {
+		return nil, ErrNilItem
+	}
+	return t.ReplaceOrInsert(item), nil
+}
+
+// ReplaceOrInsertStatus is like ReplaceOrInsert, but also reports whether an
+// existing element was replaced, which tells a replaced typed nil apart from
+// an insert.
+func (t *LLRB) ReplaceOrInsertStatus(item Item) (replaced Item, wasReplace bool) {
+	replaced = t.ReplaceOrInsert(item)
+	// A stored item is never a nil interface, even if it is a typed nil.
+	return replaced, replaced != nil
+}
+
 func (t *LLRB) replaceOrInsert(h *Node, item Item) (*Node, Item) {
 	if h == nil {
-		return newNode(item), nil
+		return newNode(t, item), nil
 	}
 
-	h = walkDownRot23(h)
+	h = t.mutable(h)
+	h = t.walkDown(h)
 
+	// An existing item that compares equal to item is replaced by item, so the
+	// new payload wins even if the comparer ignores it.
 	var replaced Item
-	if less(t.comp, item, h.Item) { // BUG
+	c := t.compare(item, h.Item)
+	if c < 0 {
 		h.Left, replaced = t.replaceOrInsert(h.Left, item)
-	} else if less(t.comp, h.Item, item) {
+	} else if c > 0 {
 		h.Right, replaced = t.replaceOrInsert(h.Right, item)
 	} else {
 		replaced, h.Item = h.Item, item
 	}
 
-	h = walkUpRot23(t, h)
+	h = t.walkUp(h)
 
 	return h, replaced
 }
@@ -199,6 +683,7 @@ func (t *LLRB) replaceOrInsert(h *Node, item Item) (*Node, Item) {
 // InsertNoReplace inserts item into the tree. If an existing
 // element has the same order, both elements remain in the tree.
 func (t *LLRB) InsertNoReplace(item Item) {
+	t.mutate()
 	if item == nil {
 		panic("inserting nil item")
 	}
@@ -209,10 +694,11 @@ func (t *LLRB) InsertNoReplace(item Item) {
 
 func (t *LLRB) insertNoReplace(h *Node, item Item) *Node {
 	if h == nil {
-		return newNode(item)
+		return newNode(t, item)
 	}
 
-	h = walkDownRot23(h)
+	h = t.mutable(h)
+	h = t.walkDown(h)
 
 	if less(t.comp, item, h.Item) {
 		h.Left = t.insertNoReplace(h.Left, item)
@@ -220,6 +706,106 @@ func (t *LLRB) insertNoReplace(h *Node, item Item) *Node {
 		h.Right = t.insertNoReplace(h.Right, item)
 	}
 
+	return t.walkUp(h)
+}
+
+// GetOrInsert returns the element in the tree that has the same order as
+// item, with loaded set to true. If there is no such element, item is
+// inserted and returned with loaded set to false. The tree is descended once.
+func (t *LLRB) GetOrInsert(item Item) (actual Item, loaded bool) {
+	t.mutate()
+	if item == nil {
+		panic("inserting nil item")
+	}
+	t.root, actual, loaded = t.getOrInsert(t.root, item)
+	t.root.Black = true
+	if !loaded {
+		t.count++
+	}
+	return actual, loaded
+}
+
+func (t *LLRB) getOrInsert(h *Node, item Item) (*Node, Item, bool) {
+	if h == nil {
+		return newNode(t, item), item, false
+	}
+
+	h = t.mutable(h)
+	h = t.walkDown(h)
+
+	var actual Item
+	var loaded bool
+	c := t.compare(item, h.Item)
+	if c < 0 {
+		h.Left, actual, loaded = t.getOrInsert(h.Left, item)
+	} else if c > 0 {
+		h.Right, actual, loaded = t.getOrInsert(h.Right, item)
+	} else {
+		actual, loaded = h.Item, true
+	}
+
+	return t.walkUp(h), actual, loaded
+}
+
+// Update finds the element in the tree whose order is the same as that of
+// key, replaces it with the result of calling fn on it, and returns true. It
+// returns false if there is no such element. The result of fn must have the
+// same order as key; Update panics otherwise and leaves the tree unchanged.
+func (t *LLRB) Update(key Item, fn func(existing Item) Item) bool {
+	t.mutate()
+	root, ok := t.update(t.root, key, fn)
+	t.root = root
+	return ok
+}
+
+// update copies only the nodes on the path to the updated element, so that
+// snapshots sharing them are unaffected.
+func (t *LLRB) update(h *Node, key Item, fn func(Item) Item) (*Node, bool) {
+	if h == nil {
+		return nil, false
+	}
+	c := t.compare(key, h.Item)
+	if c == 0 {
+		item := fn(h.Item)
+		if item == nil || t.compare(item, h.Item) != 0 {
+			panic("llrb: Update changed the order of an item")
+		}
+		h = t.mutable(h)
+		h.Item = item
+		return h, true
+	}
+	var x *Node
+	var ok bool
+	if c < 0 {
+		x, ok = t.update(h.Left, key, fn)
+	} else {
+		x, ok = t.update(h.Right, key, fn)
+	}
+	if !ok {
+		return h, false
+	}
+	h = t.mutable(h)
+	if c < 0 {
+		h.Left = x
+	} else {
+		h.Right = x
+	}
+	return h, true
+}
+
+// walkDown and walkUp dispatch to the rotation drivers of the tree's mode.
+
+func (t *LLRB) walkDown(h *Node) *Node {
+	if t.mode == Mode234 {
+		return walkDownRot234(t, h)
+	}
+	return walkDownRot23(h)
+}
+
+func (t *LLRB) walkUp(h *Node) *Node {
+	if t.mode == Mode234 {
+		return walkUpRot234(t, h)
+	}
 	return walkUpRot23(t, h)
 }
 
@@ -228,12 +814,14 @@ func (t *LLRB) insertNoReplace(h *Node, item Item) *Node {
 func walkDownRot23(h *Node) *Node { return h }
 
 func walkUpRot23(t *LLRB, h *Node) *Node {
+	fixSize(h)
+
 	if isRed(h.Right) && !isRed(h.Left) {
-		h = rotateLeft(h)
+		h = rotateLeft(t, h)
 	}
 
 	if isRed(h.Left) && isRed(h.Left.Left) {
-		h = rotateRight(h)
+		h = rotateRight(t, h)
 	}
 
 	if isRed(h.Left) && isRed(h.Right) {
@@ -253,13 +841,15 @@ func walkDownRot234(t *LLRB, h *Node) *Node {
 	return h
 }
 
-func walkUpRot234(h *Node) *Node {
+func walkUpRot234(t *LLRB, h *Node) *Node {
+	fixSize(h)
+
 	if isRed(h.Right) && !isRed(h.Left) {
-		h = rotateLeft(h)
+		h = rotateLeft(t, h)
 	}
 
 	if isRed(h.Left) && isRed(h.Left.Left) {
-		h = rotateRight(h)
+		h = rotateRight(t, h)
 	}
 
 	return h
@@ -268,6 +858,7 @@ func walkUpRot234(h *Node) *Node {
 // DeleteMin deletes the minimum element in the tree and returns the
 // deleted item or nil otherwise.
 func (t *LLRB) DeleteMin() Item {
+	t.mutate()
 	var deleted Item
 	t.root, deleted = deleteMin(t, t.root)
 	if t.root != nil {
@@ -279,28 +870,60 @@ func (t *LLRB) DeleteMin() Item {
 	return deleted
 }
 
-// deleteMin code for LLRB 2-3 trees
+// deleteMin removes the minimum element below h. Like delete, it keeps the
+// path it descends on an explicit stack rather than recursing.
 func deleteMin(t *LLRB, h *Node) (*Node, Item) {
 	if h == nil {
 		return nil, nil
 	}
-	if h.Left == nil {
-		return nil, h.Item
-	}
+	var buf [64]step
+	path, min := t.descendMin(buf[:0], h)
+	deleted := min.Item
+	t.release(min)
+	return t.fixPath(path, nil), deleted
+}
 
-	if !isRed(h.Left) && !isRed(h.Left.Left) {
-		h = moveRedLeft(t, h)
-	}
+// step is a node on the path of a delete, and the side on which the path
+// continues below it.
+type step struct {
+	h    *Node
+	left bool
+}
 
-	var deleted Item
-	h.Left, deleted = deleteMin(t, h.Left)
+// descendMin walks down the left spine from h, moving red links left as
+// deleteMin does, and appends the nodes above the minimum to path. It returns
+// path and the minimum node, which is to be removed.
+func (t *LLRB) descendMin(path []step, h *Node) ([]step, *Node) {
+	for h.Left != nil {
+		h = t.mutable(h)
+		if !isRed(h.Left) && !isRed(h.Left.Left) {
+			h = moveRedLeft(t, h)
+		}
+		path = append(path, step{h, true})
+		h = h.Left
+	}
+	return path, h
+}
 
-	return fixUp(t, h), deleted
+// fixPath links r in place of the subtree below the last step of path, and
+// fixes up every node on the path, bottom up. It returns the new root.
+func (t *LLRB) fixPath(path []step, r *Node) *Node {
+	for i := len(path) - 1; i >= 0; i-- {
+		h := path[i].h
+		if path[i].left {
+			h.Left = r
+		} else {
+			h.Right = r
+		}
+		r = fixUp(t, h)
+	}
+	return r
 }
 
 // DeleteMax deletes the maximum element in the tree and returns
 // the deleted item or nil otherwise
 func (t *LLRB) DeleteMax() Item {
+	t.mutate()
 	var deleted Item
 	t.root, deleted = deleteMax(t, t.root)
 	if t.root != nil {
@@ -316,11 +939,14 @@ func deleteMax(t *LLRB, h *Node) (*Node, Item) {
 	if h == nil {
 		return nil, nil
 	}
-	if isRed(h.Left) {
-		h = rotateRight(h)
+	h = t.mutable(h)
+	if isRed(h.Left) && !isRed(h.Right) {
+		h = rotateRight(t, h)
 	}
 	if h.Right == nil {
-		return nil, h.Item
+		deleted := h.Item
+		t.release(h)
+		return nil, deleted
 	}
 	if !isRed(h.Right) && !isRed(h.Right.Left) {
 		h = moveRedRight(t, h)
@@ -331,59 +957,223 @@ func deleteMax(t *LLRB, h *Node) (*Node, Item) {
 	return fixUp(t, h), deleted
 }
 
+// DeleteMinN deletes the n smallest elements in the tree, or all of them if
+// there are fewer, and returns them in ascending order. Unless n is a small
+// fraction of the tree, it takes them in one walk and rebuilds the tree from
+// the rest in O(N) time, rather than calling DeleteMin n times in O(n log N).
+func (t *LLRB) DeleteMinN(n int) []Item {
+	return t.deleteN(n, false)
+}
+
+// DeleteMaxN deletes the n largest elements in the tree, or all of them if
+// there are fewer, and returns them in descending order. It takes the same
+// time as DeleteMinN.
+func (t *LLRB) DeleteMaxN(n int) []Item {
+	return t.deleteN(n, true)
+}
+
+func (t *LLRB) deleteN(n int, max bool) []Item {
+	if n < 0 {
+		panic("llrb: negative count")
+	}
+	if n > t.count {
+		n = t.count
+	}
+	if n == 0 {
+		return nil
+	}
+	items := make([]Item, n)
+	// Below about an eighth of the tree, deleting one by one is cheaper than
+	// rebuilding; see BenchmarkDeleteMinN.
+	if n < t.count/8 {
+		del := deleteMin
+		if max {
+			del = deleteMax
+		}
+		t.mutate()
+		for i := range items {
+			t.root, items[i] = del(t, t.root)
+			t.root.Black = true
+		}
+		t.count -= n
+		return items
+	}
+	all := t.ToSlice()
+	rest := all[n:]
+	if max {
+		rest = all[:len(all)-n]
+		for i := range items {
+			items[i] = all[len(all)-1-i]
+		}
+	} else {
+		copy(items, all)
+	}
+	t.Clear()
+	t.loadSorted(rest)
+	return items
+}
+
 // Delete deletes an item from the tree whose key equals key.
 // The deleted item is return, otherwise nil is returned.
+// Of several items with the same key, exactly one is deleted.
 func (t *LLRB) Delete(key Item) Item {
+	item, _ := t.Delete2(key)
+	return item
+}
+
+// DeleteAll deletes every item in the tree whose key equals key, and returns
+// the number of items deleted.
+func (t *LLRB) DeleteAll(key Item) int {
+	n := t.Count(key)
+	for i := 0; i < n; i++ {
+		t.Delete2(key)
+	}
+	return n
+}
+
+// Delete2 is like Delete, but also reports whether an element was deleted.
+func (t *LLRB) Delete2(key Item) (Item, bool) {
+	t.mutate()
+	var seq uint64
+	if t.stable {
+		seq = t.oldest(key)
+	}
 	var deleted Item
-	t.root, deleted = t.delete(t.root, key)
+	t.root, deleted = t.delete(t.root, key, seq)
 	if t.root != nil {
 		t.root.Black = true
 	}
-	if deleted != nil {
-		t.count--
+	// A stored item is never a nil interface, even if it is a typed nil.
+	if deleted == nil {
+		return nil, false
 	}
-	return deleted
+	t.count--
+	return deleted, true
 }
 
-func (t *LLRB) delete(h *Node, item Item) (*Node, Item) {
-	var deleted Item
-	if h == nil {
-		return nil, nil
+// oldest returns the sequence number of the leftmost, and so oldest, item
+// whose order is the same as that of key, or zero if there is none.
+func (t *LLRB) oldest(key Item) uint64 {
+	var seq uint64
+	h := t.root
+	for h != nil {
+		c := t.compare(key, h.Item)
+		if c > 0 {
+			h = h.Right
+			continue
+		}
+		if c == 0 {
+			seq = h.seq
+		}
+		h = h.Left
 	}
-	if less(t.comp, item, h.Item) {
-		if h.Left == nil { // item not present. Nothing to delete
-			return h, nil
+	return seq
+}
+
+// DeleteRange deletes every item in the tree that is greater or equal to
+// greaterOrEqual and less than lessThan, and returns the items deleted in
+// ascending order. It takes O((k+1) log n) time to delete k items.
+func (t *LLRB) DeleteRange(greaterOrEqual, lessThan Item) []Item {
+	items := t.Between(greaterOrEqual, lessThan)
+	// Items of the same order are all in the range, so it does not matter
+	// which of them each Delete removes.
+	for _, i := range items {
+		t.Delete(i)
+	}
+	return items
+}
+
+// DeleteRangeCount is like DeleteRange, but returns only the number of items
+// deleted, without collecting them.
+func (t *LLRB) DeleteRangeCount(greaterOrEqual, lessThan Item) int {
+	n := t.CountRange(greaterOrEqual, lessThan)
+	for i := 0; i < n; i++ {
+		t.Delete(t.Ceiling(greaterOrEqual))
+	}
+	return n
+}
+
+// DeleteIf deletes every item in the tree for which pred returns true, and
+// returns the number of items deleted. It calls pred on every item in
+// ascending order, and then rebuilds the tree from the items kept, which takes
+// O(n) time. Of several items of the same order, only those matched by pred
+// are deleted.
+func (t *LLRB) DeleteIf(pred func(Item) bool) int {
+	kept := make([]Item, 0, t.count)
+	t.Ascend(func(i Item) bool {
+		if !pred(i) {
+			kept = append(kept, i)
 		}
-		if !isRed(h.Left) && !isRed(h.Left.Left) {
-			h = moveRedLeft(t, h)
+		return true
+	})
+	n := t.count - len(kept)
+	if n > 0 {
+		t.Clear()
+		t.loadSorted(kept)
+	}
+	return n
+}
+
+func (t *LLRB) delete(h *Node, item Item, seq uint64) (*Node, Item) {
+	var buf [64]step
+	path := buf[:0]
+	var deleted Item
+	var r *Node // Replaces the subtree below the last step of path
+	for h != nil {
+		h = t.mutable(h)
+		c := t.compareNode(item, seq, h)
+		if c < 0 {
+			if h.Left == nil { // item not present. Nothing to delete
+				r = h
+				break
+			}
+			if !isRed(h.Left) && !isRed(h.Left.Left) {
+				h = moveRedLeft(t, h)
+			}
+			path = append(path, step{h, true})
+			h = h.Left
+			continue
 		}
-		h.Left, deleted = t.delete(h.Left, item)
-	} else {
-		if isRed(h.Left) {
-			h = rotateRight(h)
+		// Rotations below change h.Item, in which case c is recomputed.
+		// From the above, @item is never less than the new @h.Item.
+		// A 2-3-4 4-node already has a red right link and is left alone.
+		if isRed(h.Left) && !isRed(h.Right) {
+			h = rotateRight(t, h)
+			c = t.compareNode(item, seq, h)
 		}
 		// If @item equals @h.Item and no right children at @h
-		if !less(t.comp, h.Item, item) && h.Right == nil {
-			return nil, h.Item
+		if c == 0 && h.Right == nil {
+			deleted = h.Item
+			t.release(h)
+			break
 		}
 		// PETAR: Added 'h.Right != nil' below
 		if h.Right != nil && !isRed(h.Right) && !isRed(h.Right.Left) {
-			h = moveRedRight(t, h)
-		}
-		// If @item equals @h.Item, and (from above) 'h.Right != nil'
-		if !less(t.comp, h.Item, item) {
-			var subDeleted Item
-			h.Right, subDeleted = deleteMin(t, h.Right)
-			if subDeleted == nil {
-				panic("logic")
+			// The rotation moves h.Item down to the right, where the search
+			// continues. @item is bigger than the new @h.Item, or equal to it
+			// if there are duplicates, but the new @h.Right may lean right
+			// and cannot give up its minimum yet.
+			if x := moveRedRight(t, h); x != h {
+				h = x
+				c = 1
 			}
-			deleted, h.Item = h.Item, subDeleted
-		} else { // Else, @item is bigger than @h.Item
-			h.Right, deleted = t.delete(h.Right, item)
 		}
+		path = append(path, step{h, false})
+		// If @item equals @h.Item, and (from above) 'h.Right != nil', then
+		// @h.Item is replaced by its successor, which is deleted instead. The
+		// successor's sequence number moves along with its item.
+		if c == 0 {
+			var min *Node
+			path, min = t.descendMin(path, h.Right)
+			deleted, h.Item, h.seq = h.Item, min.Item, min.seq
+			t.release(min)
+			break
+		}
+		// Else, @item is bigger than @h.Item
+		h = h.Right
 	}
 
-	return fixUp(t, h), deleted
+	return t.fixPath(path, r), deleted
 }
 
 func spaces(num int) string {
@@ -394,19 +1184,60 @@ func spaces(num int) string {
 	return ret
 }
 
+// PrintTree writes the subtree rooted at n to standard output.
 func PrintTree(n *Node, depth int) {
+	FprintTree(os.Stdout, n, depth)
+}
+
+// FprintTree writes the subtree rooted at n to w, one node per line in
+// pre-order, indented by depth.
+func FprintTree(w io.Writer, n *Node, depth int) {
 	if n == nil {
-		fmt.Printf("%s%v\n", spaces(depth), nil)
+		fmt.Fprintf(w, "%s%v\n", spaces(depth), nil)
 	} else {
-		fmt.Printf("%s%t%v\n", spaces(depth), n.Black, n.Item)
-		PrintTree(n.Left, depth+1)
-		PrintTree(n.Right, depth+1)
+		fmt.Fprintf(w, "%s%t%v\n", spaces(depth), n.Black, n.Item)
+		FprintTree(w, n.Left, depth+1)
+		FprintTree(w, n.Right, depth+1)
 	}
 }
 
 // Internal node manipulation routines
 
-func newNode(item Item) *Node { return &Node{Item: item} }
+func newNode(t *LLRB, item Item) *Node {
+	h := t.free
+	if h != nil {
+		t.free = h.Right
+		*h = Node{Item: item, size: 1, owner: t.owner}
+	} else {
+		h = &Node{Item: item, size: 1, owner: t.owner}
+	}
+	if t.stable {
+		t.seq++
+		h.seq = t.seq
+	}
+	return h
+}
+
+// release returns a node that has been removed from a pooled tree to the free
+// list. Nodes shared with a snapshot, or that a traversal in progress may
+// still visit, are left to the garbage collector.
+func (t *LLRB) release(h *Node) {
+	if !t.pool || h.owner != t.owner || atomic.LoadInt32(&t.walking) > 0 {
+		return
+	}
+	*h = Node{Right: t.free}
+	t.free = h
+}
+
+func size(h *Node) int {
+	if h == nil {
+		return 0
+	}
+	return h.size
+}
+
+// fixSize recomputes the size of h from the sizes of its children.
+func fixSize(h *Node) { h.size = 1 + size(h.Left) + size(h.Right) }
 
 func isRed(h *Node) bool {
 	if h == nil {
@@ -415,8 +1246,8 @@ func isRed(h *Node) bool {
 	return !h.Black
 }
 
-func rotateLeft(h *Node) *Node {
-	x := h.Right
+func rotateLeft(t *LLRB, h *Node) *Node {
+	x := t.mutable(h.Right)
 	if x.Black {
 		panic("rotating a black link")
 	}
@@ -424,11 +1255,13 @@ func rotateLeft(h *Node) *Node {
 	x.Left = h
 	x.Black = h.Black
 	h.Black = false
+	x.size = h.size
+	fixSize(h)
 	return x
 }
 
-func rotateRight(h *Node) *Node {
-	x := h.Left
+func rotateRight(t *LLRB, h *Node) *Node {
+	x := t.mutable(h.Left)
 	if x.Black {
 		panic("rotating a black link")
 	}
@@ -436,25 +1269,30 @@ func rotateRight(h *Node) *Node {
 	x.Right = h
 	x.Black = h.Black
 	h.Black = false
+	x.size = h.size
+	fixSize(h)
 	return x
 }
 
-func quitOnNil(t *LLRB, h *Node) {
-	if h == nil {
-		fmt.Println("About to choke on referencing a nil node.")
-		PrintTree(t.root, 0)
-		debug.PrintStack()
-		os.Exit(-1)
+// panicOnNil is a debug check, enabled with SetStrict, that reports a nil
+// node before it is dereferenced. The panic message holds the whole tree.
+func panicOnNil(t *LLRB, h *Node) {
+	if t.strict && h == nil {
+		var b strings.Builder
+		FprintTree(&b, t.root, 0)
+		panic("llrb: about to dereference a nil node in tree:\n" + b.String())
 	}
 }
 
 // REQUIRE: Left and Right children must be present
 func flip(t *LLRB, h *Node) {
-	quitOnNil(t, h)
+	panicOnNil(t, h)
 	h.Black = !h.Black
-	quitOnNil(t, h.Left)
+	panicOnNil(t, h.Left)
+	h.Left = t.mutable(h.Left)
 	h.Left.Black = !h.Left.Black
-	quitOnNil(t, h.Right)
+	panicOnNil(t, h.Right)
+	h.Right = t.mutable(h.Right)
 	h.Right.Black = !h.Right.Black
 }
 
@@ -462,9 +1300,13 @@ func flip(t *LLRB, h *Node) {
 func moveRedLeft(t *LLRB, h *Node) *Node {
 	flip(t, h) // can fail here
 	if isRed(h.Right.Left) {
-		h.Right = rotateRight(h.Right)
-		h = rotateLeft(h)
+		h.Right = rotateRight(t, h.Right)
+		h = rotateLeft(t, h)
 		flip(t, h)
+		// What remains of a 2-3-4 4-node sibling leans right.
+		if isRed(h.Right.Right) {
+			h.Right = rotateLeft(t, h.Right)
+		}
 	}
 	return h
 }
@@ -472,20 +1314,31 @@ func moveRedLeft(t *LLRB, h *Node) *Node {
 // REQUIRE: Left and Right children must be present
 func moveRedRight(t *LLRB, h *Node) *Node {
 	flip(t, h) // can fail here
+	// Borrow a single key from a 2-3-4 4-node sibling, not two.
+	if isRed(h.Left.Right) {
+		h.Left = rotateLeft(t, h.Left)
+	}
 	if isRed(h.Left.Left) {
-		h = rotateRight(h)
+		h = rotateRight(t, h)
 		flip(t, h)
 	}
 	return h
 }
 
 func fixUp(t *LLRB, h *Node) *Node {
+	if t.mode == Mode234 {
+		// 4-nodes are legal here; splitting them would push a red link
+		// into a parent that may already be full.
+		return walkUpRot234(t, h)
+	}
+	fixSize(h)
+
 	if isRed(h.Right) {
-		h = rotateLeft(h)
+		h = rotateLeft(t, h)
 	}
 
 	if isRed(h.Left) && isRed(h.Left.Left) {
-		h = rotateRight(h)
+		h = rotateRight(t, h)
 	}
 
 	if isRed(h.Left) && isRed(h.Right) {
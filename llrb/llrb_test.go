@@ -5,8 +5,12 @@
 package llrb
 
 import (
+	"bytes"
+	"fmt"
 	"math"
 	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -188,6 +192,85 @@ func TestRandomInsertStats(t *testing.T) {
 	}
 }
 
+func TestHeight(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if tree.Height() != 0 || tree.BlackHeight() != 0 {
+		t.Errorf("expected zero heights for an empty tree")
+	}
+	n := 100000
+	for i := 0; i < n; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	if h, max := tree.Height(), 2*math.Log2(float64(n)); float64(h) > max {
+		t.Errorf("height %d exceeds %.1f", h, max)
+	}
+	if h, min := tree.Height(), math.Log2(float64(n+1)); float64(h) < min {
+		t.Errorf("height %d is below %.1f", h, min)
+	}
+	checkInvariants(t, tree)
+	bh := 0
+	for h := tree.Root(); h != nil; h = h.Right {
+		if h.Black {
+			bh++
+		}
+	}
+	if tree.BlackHeight() != bh {
+		t.Errorf("BlackHeight() = %d, expected %d", tree.BlackHeight(), bh)
+	}
+}
+
+func TestGetCounted(t *testing.T) {
+	trees := []*LLRB{New(NaturalSortLessInt), NewCmp(func(a, b interface{}) int { return int(a.(Int) - b.(Int)) })}
+	for _, tree := range trees {
+		if item, calls := tree.GetCounted(Int(1)); item != nil || calls != 0 {
+			t.Errorf("GetCounted on an empty tree = %v, %d", item, calls)
+		}
+		for _, i := range rand.Perm(10000) {
+			tree.ReplaceOrInsert(Int(2 * i))
+		}
+		max := 2 * tree.Height()
+		if tree.cmp != nil {
+			max = tree.Height()
+		}
+		for i := -1; i < 20001; i++ {
+			item, calls := tree.GetCounted(Int(i))
+			if item != tree.Get(Int(i)) {
+				t.Fatalf("GetCounted(%d) returned %v", i, item)
+			}
+			if calls < 1 || calls > max {
+				t.Fatalf("GetCounted(%d) made %d calls, expected 1 to %d", i, calls, max)
+			}
+		}
+	}
+}
+
+func TestMemStats(t *testing.T) {
+	tree := NewPooled(NaturalSortLessInt)
+	if nodes, bytes := tree.MemStats(); nodes != 0 || bytes != 0 {
+		t.Errorf("expected an empty tree to take no memory, got %d nodes, %d bytes", nodes, bytes)
+	}
+	for i := 0; i < 1000; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	nodes, bytes := tree.MemStats()
+	if nodes != 1000 || bytes <= 0 {
+		t.Fatalf("expected 1000 nodes, got %d nodes, %d bytes", nodes, bytes)
+	}
+	for i := 1000; i < 3000; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	if n, b := tree.MemStats(); n != 3000 || b != 3*bytes {
+		t.Errorf("expected 3000 nodes and %d bytes, got %d nodes, %d bytes", 3*bytes, n, b)
+	}
+	// Deleted nodes are kept for reuse.
+	for i := 0; i < 1000; i++ {
+		tree.DeleteMin()
+	}
+	if n, b := tree.MemStats(); n != 3000 || b != 3*bytes {
+		t.Errorf("expected the pool to keep 3000 nodes, got %d nodes, %d bytes", n, b)
+	}
+}
+
 func BenchmarkInsert(b *testing.B) {
 	tree := New(NaturalSortLessInt)
 	for i := 0; i < b.N; i++ {
@@ -207,6 +290,38 @@ func BenchmarkDelete(b *testing.B) {
 	}
 }
 
+func BenchmarkDeleteRecursive(b *testing.B) {
+	b.StopTimer()
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < b.N; i++ {
+		tree.ReplaceOrInsert(Int(b.N - i))
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		tree.root, _ = deleteRecursive(tree, tree.root, Int(i), 0)
+		if tree.root != nil {
+			tree.root.Black = true
+		}
+	}
+}
+
+func benchmarkChurn(b *testing.B, tree *LLRB) {
+	n := 10000
+	for i := 0; i < n; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Delete(Int(i % n))
+		tree.ReplaceOrInsert(Int(i % n))
+	}
+}
+
+func BenchmarkChurn(b *testing.B) { benchmarkChurn(b, New(NaturalSortLessInt)) }
+
+func BenchmarkChurnPooled(b *testing.B) { benchmarkChurn(b, NewPooled(NaturalSortLessInt)) }
+
 func BenchmarkDeleteMin(b *testing.B) {
 	b.StopTimer()
 	tree := New(NaturalSortLessInt)
@@ -237,3 +352,1360 @@ func TestInsertNoReplace(t *testing.T) {
 		return true
 	})
 }
+
+func TestGetOrInsert(t *testing.T) {
+	for _, mode := range []Mode{Mode23, Mode234} {
+		tree := NewWithMode(lessTagged, mode)
+		n := 1000
+		for _, i := range rand.Perm(n) {
+			if actual, loaded := tree.GetOrInsert(tagged{Int(i), 1}); loaded || actual != (tagged{Int(i), 1}) {
+				t.Fatalf("miss on %d returned %v, %v", i, actual, loaded)
+			}
+		}
+		for _, i := range rand.Perm(n) {
+			if actual, loaded := tree.GetOrInsert(tagged{Int(i), 2}); !loaded || actual != (tagged{Int(i), 1}) {
+				t.Fatalf("hit on %d returned %v, %v", i, actual, loaded)
+			}
+		}
+		if tree.Len() != n {
+			t.Errorf("expected len %d, got %d", n, tree.Len())
+		}
+		checkInvariants(t, tree)
+	}
+}
+
+// benchmarkGetOrInsert runs a miss-heavy workload and reports the number of
+// comparisons made per lookup-or-insert.
+func benchmarkGetOrInsert(b *testing.B, getOrInsert func(tree *LLRB, item Item)) {
+	var cmps int
+	tree := New(func(a, b interface{}) bool {
+		cmps++
+		return a.(Int) < b.(Int)
+	})
+	perm := rand.Perm(b.N)
+	b.ResetTimer()
+	for _, i := range perm {
+		getOrInsert(tree, Int(i))
+	}
+	b.ReportMetric(float64(cmps)/float64(b.N), "cmps/op")
+}
+
+func BenchmarkGetOrInsert(b *testing.B) {
+	benchmarkGetOrInsert(b, func(tree *LLRB, item Item) {
+		tree.GetOrInsert(item)
+	})
+}
+
+func BenchmarkGetThenInsert(b *testing.B) {
+	benchmarkGetOrInsert(b, func(tree *LLRB, item Item) {
+		if tree.Get(item) == nil {
+			tree.ReplaceOrInsert(item)
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	tree := New(lessTagged)
+	n := 100
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(tagged{Int(i), 0})
+	}
+	snap := tree.Snapshot()
+	for i := 0; i < n; i += 2 {
+		ok := tree.Update(tagged{Int(i), -1}, func(x Item) Item {
+			return tagged{x.(tagged).key, x.(tagged).tag + 1}
+		})
+		if !ok {
+			t.Fatalf("update of %d failed", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if x := tree.Get(tagged{Int(i), -1}).(tagged); x.tag != 1-i%2 {
+			t.Errorf("item %d has tag %d, expected %d", i, x.tag, 1-i%2)
+		}
+		if x := snap.Get(tagged{Int(i), -1}).(tagged); x.tag != 0 {
+			t.Errorf("snapshot item %d has tag %d, expected 0", i, x.tag)
+		}
+	}
+	if tree.Update(tagged{Int(n), -1}, func(x Item) Item { return x }) {
+		t.Errorf("update of a missing key succeeded")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected a panic when the key is changed")
+			}
+		}()
+		tree.Update(tagged{Int(1), -1}, func(x Item) Item { return tagged{Int(n + 1), 0} })
+	}()
+	if !tree.Has(tagged{Int(1), -1}) || tree.Has(tagged{Int(n + 1), -1}) {
+		t.Errorf("rejected update modified the tree")
+	}
+	checkInvariants(t, tree)
+}
+
+func TestStable(t *testing.T) {
+	tree := NewStable(lessTagged)
+	n := 300
+	for i := 0; i < n; i++ {
+		tree.InsertNoReplace(tagged{Int(i % 3), i})
+	}
+	last := map[Int]int{0: -1, 1: -1, 2: -1}
+	tree.Ascend(func(i Item) bool {
+		x := i.(tagged)
+		if x.tag <= last[x.key] {
+			t.Fatalf("key %d: tag %d visited after tag %d", x.key, x.tag, last[x.key])
+		}
+		last[x.key] = x.tag
+		return true
+	})
+	deleted := map[Int]int{}
+	for k, i := range rand.Perm(n) {
+		key := Int(i % 3)
+		oldest := 3*deleted[key] + int(key)
+		d := tree.Delete(tagged{key, -1})
+		if d == nil || d.(tagged).tag != oldest {
+			t.Fatalf("key %d: deleted %v, expected the oldest tag %d", key, d, oldest)
+		}
+		deleted[key]++
+		if k%30 == 0 {
+			checkInvariants(t, tree)
+		}
+	}
+	if tree.Len() != 0 {
+		t.Errorf("expected empty tree, got len %d", tree.Len())
+	}
+}
+
+func TestNewPooled(t *testing.T) {
+	tree := NewPooled(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	snap := tree.Snapshot()
+	for _, i := range rand.Perm(n) {
+		tree.Delete(Int(i))
+	}
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(n + i))
+		if i%2 == 0 {
+			tree.DeleteMin()
+		} else {
+			tree.DeleteMax()
+		}
+		tree.ReplaceOrInsert(Int(n + i))
+	}
+	checkInvariants(t, tree)
+	checkInvariants(t, snap)
+	if snap.Len() != n || snap.Min() != Int(0) || snap.Max() != Int(n-1) {
+		t.Fatalf("reusing nodes corrupted a snapshot")
+	}
+	for free := tree.free; free != nil; free = free.Right {
+		if free.Item != nil || free.Left != nil || free.Black || free.size != 0 {
+			t.Fatalf("free node was not reset")
+		}
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		tree.Delete(Int(n))
+		tree.ReplaceOrInsert(Int(n))
+	})
+	if allocs != 0 {
+		t.Errorf("expected deleted nodes to be reused, got %.1f allocations", allocs)
+	}
+}
+
+func TestClear(t *testing.T) {
+	for _, tree := range []*LLRB{New(NaturalSortLessInt), NewPooled(NaturalSortLessInt)} {
+		for _, i := range rand.Perm(100) {
+			tree.ReplaceOrInsert(Int(i))
+		}
+		snap := tree.Snapshot()
+		tree.ReplaceOrInsert(Int(100))
+		tree.Clear()
+		if tree.Len() != 0 || tree.Get(Int(1)) != nil || tree.Root() != nil {
+			t.Fatalf("pool=%v: tree not empty after Clear", tree.pool)
+		}
+		for i := 0; i < 10; i++ {
+			tree.ReplaceOrInsert(Int(i))
+		}
+		checkInvariants(t, tree)
+		if tree.Len() != 10 || tree.Get(Int(5)) != Int(5) {
+			t.Errorf("pool=%v: inserts after Clear failed", tree.pool)
+		}
+		checkInvariants(t, snap)
+		if snap.Len() != 100 || snap.Max() != Int(99) {
+			t.Errorf("pool=%v: Clear modified a snapshot", tree.pool)
+		}
+	}
+}
+
+func TestDeleteMinMaxN(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 100
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	mods := tree.mods
+	if items := tree.DeleteMinN(0); len(items) != 0 || tree.Len() != n {
+		t.Fatalf("DeleteMinN(0) deleted %v", items)
+	}
+	if items := tree.DeleteMaxN(0); len(items) != 0 || tree.mods != mods {
+		t.Fatalf("deleting no items modified the tree")
+	}
+	min := tree.DeleteMinN(10)
+	max := tree.DeleteMaxN(10)
+	for k := 0; k < 10; k++ {
+		if min[k] != Int(k) || max[k] != Int(n-1-k) {
+			t.Fatalf("item %d: got %v and %v", k, min[k], max[k])
+		}
+	}
+	checkInvariants(t, tree)
+	if tree.Len() != n-20 || tree.Min() != Int(10) || tree.Max() != Int(n-11) {
+		t.Fatalf("unexpected tree of len %d from %v to %v", tree.Len(), tree.Min(), tree.Max())
+	}
+	rest := tree.DeleteMaxN(n)
+	if len(rest) != n-20 || rest[0] != Int(n-11) || rest[len(rest)-1] != Int(10) {
+		t.Fatalf("DeleteMaxN(%d) returned %d items from %v to %v", n, len(rest), rest[0], rest[len(rest)-1])
+	}
+	if tree.Len() != 0 || tree.Root() != nil {
+		t.Errorf("expected an empty tree")
+	}
+	if items := tree.DeleteMinN(1); len(items) != 0 {
+		t.Errorf("DeleteMinN on an empty tree returned %v", items)
+	}
+	// Deleting more than an eighth of the tree rebuilds it from the rest.
+	for _, tree := range []*LLRB{NewStable(lessTagged), NewPooled(lessTagged)} {
+		for i := 0; i < 300; i++ {
+			tree.InsertNoReplace(tagged{Int(i / 3), i})
+		}
+		all := tree.ToSlice()
+		min := tree.DeleteMinN(100)
+		max := tree.DeleteMaxN(100)
+		for k := 0; k < 100; k++ {
+			if min[k] != all[k] || max[k] != all[299-k] {
+				t.Fatalf("item %d: got %v and %v", k, min[k], max[k])
+			}
+		}
+		checkInvariants(t, tree)
+		if rest := tree.ToSlice(); !reflect.DeepEqual(rest, all[100:200]) {
+			t.Errorf("expected %v left, got %v", all[100:200], rest)
+		}
+	}
+}
+
+func TestTryReplaceOrInsert(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	tree.ReplaceOrInsert(Int(1))
+	if replaced, err := tree.TryReplaceOrInsert(nil); err != ErrNilItem || replaced != nil {
+		t.Errorf("expected ErrNilItem, got %v, %v", replaced, err)
+	}
+	if tree.Len() != 1 || tree.Min() != Int(1) {
+		t.Errorf("nil insert modified the tree")
+	}
+	if replaced, err := tree.TryReplaceOrInsert(Int(1)); err != nil || replaced != Int(1) {
+		t.Errorf("expected to replace 1, got %v, %v", replaced, err)
+	}
+	if replaced, err := tree.TryReplaceOrInsert(Int(2)); err != nil || replaced != nil || tree.Len() != 2 {
+		t.Errorf("expected to insert 2, got %v, %v", replaced, err)
+	}
+}
+
+func TestFlipNilChildPanics(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		tree := New(NaturalSortLessInt)
+		tree.SetStrict(strict)
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Errorf("strict=%v: expected a recoverable panic", strict)
+				}
+				if msg, _ := r.(string); strict && !strings.HasPrefix(msg, "llrb: about to dereference a nil node") {
+					t.Errorf("expected the strict check to panic, got %v", r)
+				}
+			}()
+			flip(tree, newNode(tree, Int(1)))
+		}()
+	}
+}
+
+func TestFprintTree(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	tree.ReplaceOrInsert(Int(2))
+	tree.ReplaceOrInsert(Int(1))
+	tree.ReplaceOrInsert(Int(3))
+	var buf bytes.Buffer
+	FprintTree(&buf, tree.Root(), 0)
+	expected := "true2\n" +
+		"  true1\n    <nil>\n    <nil>\n" +
+		"  true3\n    <nil>\n    <nil>\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+func TestFoundFlags(t *testing.T) {
+	val := func(i interface{}) int {
+		if p := i.(*int); p != nil {
+			return *p
+		}
+		return -1
+	}
+	tree := New(func(a, b interface{}) bool { return val(a) < val(b) })
+	if _, ok := tree.Min2(); ok {
+		t.Errorf("Min2 found an element in an empty tree")
+	}
+	if _, ok := tree.Max2(); ok {
+		t.Errorf("Max2 found an element in an empty tree")
+	}
+	one := 1
+	tree.ReplaceOrInsert((*int)(nil))
+	tree.ReplaceOrInsert(&one)
+
+	// Get cannot tell a missing item from a stored typed nil.
+	missing := 2
+	p1, _ := tree.Get((*int)(nil)).(*int)
+	p2, _ := tree.Get(&missing).(*int)
+	if p1 != nil || p2 != nil {
+		t.Fatalf("expected nil from both lookups")
+	}
+	if i, ok := tree.Get2((*int)(nil)); !ok || i.(*int) != nil {
+		t.Errorf("Get2 of the typed nil = %v, %v", i, ok)
+	}
+	if i, ok := tree.Get2(&missing); ok || i != nil {
+		t.Errorf("Get2 of a missing item = %v, %v", i, ok)
+	}
+	if i, ok := tree.Min2(); !ok || i.(*int) != nil {
+		t.Errorf("Min2 = %v, %v", i, ok)
+	}
+	if i, ok := tree.Max2(); !ok || i.(*int) != &one {
+		t.Errorf("Max2 = %v, %v", i, ok)
+	}
+	if i, ok := tree.Delete2(&missing); ok || i != nil || tree.Len() != 2 {
+		t.Errorf("Delete2 of a missing item = %v, %v", i, ok)
+	}
+	if i, ok := tree.Delete2((*int)(nil)); !ok || i.(*int) != nil || tree.Len() != 1 {
+		t.Errorf("Delete2 of the typed nil = %v, %v", i, ok)
+	}
+}
+
+func TestMultiset(t *testing.T) {
+	tree := New(lessTagged)
+	for i := 0; i < 5; i++ {
+		tree.InsertNoReplace(tagged{1, i})
+		tree.InsertNoReplace(tagged{2, i})
+	}
+	tree.InsertNoReplace(tagged{3, 0})
+	if c := tree.Count(tagged{2, 0}); c != 5 {
+		t.Errorf("expected 5 copies, got %d", c)
+	}
+	if tree.Delete(tagged{2, 0}) == nil || tree.Count(tagged{2, 0}) != 4 || tree.Len() != 10 {
+		t.Errorf("expected Delete to remove exactly one copy")
+	}
+	if n := tree.DeleteAll(tagged{1, 0}); n != 5 || tree.Count(tagged{1, 0}) != 0 || tree.Len() != 5 {
+		t.Errorf("DeleteAll removed %d copies, expected 5", n)
+	}
+	if n := tree.DeleteAll(tagged{7, 0}); n != 0 || tree.Len() != 5 {
+		t.Errorf("DeleteAll of a missing key removed %d items", n)
+	}
+	checkInvariants(t, tree)
+}
+
+// TestDeleteDuplicates deletes single copies of keys stored many times over.
+// Rotations on the way down may bring an equal item to the top of the subtree
+// being searched, which must not be taken for the item to delete.
+func TestDeleteDuplicates(t *testing.T) {
+	for _, mode := range []Mode{Mode23, Mode234} {
+		tree := NewWithMode(lessTagged, mode)
+		counts := map[Int]int{}
+		for i := 0; i < 5000; i++ {
+			k := Int(rand.Intn(12))
+			switch rand.Intn(10) {
+			case 0, 1, 2:
+				if tree.Delete(tagged{k, 0}) != nil {
+					counts[k]--
+				}
+			case 3:
+				if m := tree.DeleteMin(); m != nil {
+					counts[m.(tagged).key]--
+				}
+			case 4:
+				if m := tree.DeleteMax(); m != nil {
+					counts[m.(tagged).key]--
+				}
+			default:
+				tree.InsertNoReplace(tagged{k, i})
+				counts[k]++
+			}
+			checkInvariants(t, tree)
+		}
+		for k, c := range counts {
+			if n := tree.DeleteAll(tagged{k, 0}); n != c {
+				t.Errorf("mode %d: deleted %d copies of %d, expected %d", mode, n, k, c)
+			}
+			checkInvariants(t, tree)
+		}
+		if tree.Len() != 0 {
+			t.Errorf("mode %d: %d items left", mode, tree.Len())
+		}
+	}
+}
+
+func TestDeleteIf(t *testing.T) {
+	for _, tree := range []*LLRB{New(NaturalSortLessInt), NewPooled(NaturalSortLessInt)} {
+		for _, i := range rand.Perm(1000) {
+			tree.ReplaceOrInsert(Int(i))
+		}
+		if n := tree.DeleteIf(func(i Item) bool { return i.(Int)%2 == 0 }); n != 500 {
+			t.Errorf("expected 500 deletions, got %d", n)
+		}
+		checkInvariants(t, tree)
+		j := 1
+		tree.Ascend(func(i Item) bool {
+			if i != Int(j) {
+				t.Fatalf("expected %d, got %v", j, i)
+			}
+			j += 2
+			return true
+		})
+		if tree.Len() != 500 || j != 1001 {
+			t.Errorf("expected the 500 odd keys, got %d items", tree.Len())
+		}
+		if n := tree.DeleteIf(func(Item) bool { return false }); n != 0 || tree.Len() != 500 {
+			t.Errorf("expected nothing deleted, got %d", n)
+		}
+	}
+
+	// Only the matched duplicates are deleted.
+	tree := New(lessTagged)
+	for i := 0; i < 100; i++ {
+		tree.InsertNoReplace(tagged{Int(i % 10), i})
+	}
+	if n := tree.DeleteIf(func(i Item) bool { return i.(tagged).tag%3 == 0 }); n != 34 {
+		t.Errorf("expected 34 deletions, got %d", n)
+	}
+	checkInvariants(t, tree)
+	tree.Ascend(func(i Item) bool {
+		if i.(tagged).tag%3 == 0 {
+			t.Errorf("%v was not deleted", i)
+		}
+		return true
+	})
+}
+
+func TestGetAll(t *testing.T) {
+	tree := New(lessTagged)
+	var all []tagged
+	for i := 0; i < 3000; i++ {
+		item := tagged{Int(rand.Intn(20)), i}
+		tree.InsertNoReplace(item)
+		all = append(all, item)
+	}
+	for k := -1; k <= 20; k++ {
+		var expected []Item
+		for _, item := range all {
+			if item.key == Int(k) {
+				expected = append(expected, item)
+			}
+		}
+		got := tree.GetAll(tagged{Int(k), 0})
+		if len(got) != len(expected) {
+			t.Fatalf("GetAll(%d) returned %d items, expected %d", k, len(got), len(expected))
+		}
+		// Equal items are stored in insertion order.
+		if len(got) > 0 && !reflect.DeepEqual(got, expected) {
+			t.Errorf("GetAll(%d) = %v, expected %v", k, got, expected)
+		}
+	}
+	dst := []Item{Int(-1)}
+	dst = tree.AppendAll(dst, tagged{3, 0})
+	if dst[0] != Int(-1) || len(dst) != 1+len(tree.GetAll(tagged{3, 0})) {
+		t.Errorf("AppendAll did not extend dst")
+	}
+}
+
+func TestGetNode(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(2 * i))
+	}
+	for i := 0; i < 200; i++ {
+		h := tree.GetNode(Int(i))
+		if i%2 == 1 {
+			if h != nil {
+				t.Errorf("GetNode(%d) = %v, expected nil", i, h.Item)
+			}
+			continue
+		}
+		if h == nil || h.Item != Int(i) {
+			t.Fatalf("GetNode(%d) did not find the element", i)
+		}
+		// The node must be the one reached by searching from the root.
+		n := tree.Root()
+		for n.Item != Int(i) {
+			if Int(i) < n.Item.(Int) {
+				n = n.Left
+			} else {
+				n = n.Right
+			}
+		}
+		if n != h {
+			t.Errorf("GetNode(%d) returned a node outside the tree", i)
+		}
+	}
+	if New(NaturalSortLessInt).GetNode(Int(0)) != nil {
+		t.Errorf("GetNode found a node in an empty tree")
+	}
+}
+
+func TestNodeAccessors(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(50) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	var walk func(h *Node)
+	walk = func(h *Node) {
+		if h == nil {
+			return
+		}
+		if h.IsBlack() != h.Black || h.LeftChild() != h.Left || h.RightChild() != h.Right {
+			t.Fatalf("accessors of %v disagree with its fields", h.Item)
+		}
+		if n := tree.GetNode(h.Item); n != h {
+			t.Fatalf("GetNode(%v) returned a different node", h.Item)
+		}
+		walk(h.Left)
+		walk(h.Right)
+	}
+	walk(tree.Root())
+	var nilNode *Node
+	if !nilNode.IsBlack() || nilNode.LeftChild() != nil || nilNode.RightChild() != nil {
+		t.Errorf("expected a nil node to be a black leaf")
+	}
+}
+
+func TestReplaceOrInsertStatus(t *testing.T) {
+	val := func(i interface{}) int {
+		if p := i.(*int); p != nil {
+			return *p
+		}
+		return -1
+	}
+	tree := New(func(a, b interface{}) bool { return val(a) < val(b) })
+	one, other := 1, 1
+	if r, ok := tree.ReplaceOrInsertStatus((*int)(nil)); ok || r != nil {
+		t.Errorf("insert of a typed nil = %v, %v", r, ok)
+	}
+	if r, ok := tree.ReplaceOrInsertStatus(&one); ok || r != nil {
+		t.Errorf("insert of a new item = %v, %v", r, ok)
+	}
+	if r, ok := tree.ReplaceOrInsertStatus(&other); !ok || r.(*int) != &one {
+		t.Errorf("replace of an item = %v, %v", r, ok)
+	}
+	// The replaced item converts to a nil *int, as if nothing was replaced.
+	if r, ok := tree.ReplaceOrInsertStatus((*int)(nil)); !ok || r.(*int) != nil {
+		t.Errorf("replace of a typed nil = %v, %v", r, ok)
+	}
+	if tree.Len() != 2 {
+		t.Errorf("expected 2 items, got %d", tree.Len())
+	}
+}
+
+func TestReplaceOrInsertBulkStatus(t *testing.T) {
+	tree := New(lessTagged)
+	tree.ReplaceOrInsertBulk(tagged{1, 0}, tagged{3, 0})
+	replaced := tree.ReplaceOrInsertBulkStatus(tagged{0, 1}, tagged{1, 1}, tagged{2, 1}, tagged{3, 1}, tagged{2, 2})
+	expected := []Item{nil, tagged{1, 0}, nil, tagged{3, 0}, tagged{2, 1}}
+	if !reflect.DeepEqual(replaced, expected) {
+		t.Errorf("expected %v but got %v", expected, replaced)
+	}
+	if tree.Len() != 4 {
+		t.Errorf("expected 4 items, got %d", tree.Len())
+	}
+	if r := tree.ReplaceOrInsertBulkStatus(); len(r) != 0 {
+		t.Errorf("expected no results, got %v", r)
+	}
+}
+
+func TestMinMaxOK(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if i, ok := tree.MinOK(); ok || i != nil {
+		t.Errorf("MinOK of an empty tree = %v, %v", i, ok)
+	}
+	if i, ok := tree.MaxOK(); ok || i != nil {
+		t.Errorf("MaxOK of an empty tree = %v, %v", i, ok)
+	}
+	for _, i := range rand.Perm(10) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	if i, ok := tree.MinOK(); !ok || i != Int(0) {
+		t.Errorf("MinOK = %v, %v", i, ok)
+	}
+	if i, ok := tree.MaxOK(); !ok || i != Int(9) {
+		t.Errorf("MaxOK = %v, %v", i, ok)
+	}
+}
+
+func TestFloorCeiling(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if tree.Floor(Int(1)) != nil || tree.Ceiling(Int(1)) != nil {
+		t.Errorf("expected nil Floor and Ceiling on empty tree")
+	}
+	for i := 1; i <= 5; i++ {
+		tree.ReplaceOrInsert(Int(i * 10))
+	}
+	tests := []struct {
+		key            Int
+		floor, ceiling Item
+	}{
+		{5, nil, Int(10)},
+		{10, Int(10), Int(10)},
+		{25, Int(20), Int(30)},
+		{50, Int(50), Int(50)},
+		{55, Int(50), nil},
+	}
+	for _, test := range tests {
+		if f := tree.Floor(test.key); f != test.floor {
+			t.Errorf("Floor(%d) = %v, expected %v", test.key, f, test.floor)
+		}
+		if c := tree.Ceiling(test.key); c != test.ceiling {
+			t.Errorf("Ceiling(%d) = %v, expected %v", test.key, c, test.ceiling)
+		}
+	}
+}
+
+func TestFloorCeilingOK(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	if i, ok := tree.FloorOK(Int(1)); i != nil || ok {
+		t.Errorf("FloorOK of an empty tree = %v, %v", i, ok)
+	}
+	for i := 0; i < 100; i += 10 {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	cases := []struct {
+		key            Int
+		floor, ceiling Item
+		exact          bool
+	}{
+		{30, Int(30), Int(30), true},
+		{35, Int(30), Int(40), false},
+		{0, Int(0), Int(0), true},
+		{90, Int(90), Int(90), true},
+		{-5, nil, Int(0), false},
+		{95, Int(90), nil, false},
+	}
+	for _, c := range cases {
+		if i, ok := tree.FloorOK(c.key); i != c.floor || ok != c.exact {
+			t.Errorf("FloorOK(%d) = %v, %v", c.key, i, ok)
+		}
+		if i, ok := tree.CeilingOK(c.key); i != c.ceiling || ok != c.exact {
+			t.Errorf("CeilingOK(%d) = %v, %v", c.key, i, ok)
+		}
+	}
+}
+
+func TestFindFirst(t *testing.T) {
+	tree := New(lessTagged)
+	if tree.FindFirst(func(Item) bool { return true }) != nil {
+		t.Errorf("found an item in an empty tree")
+	}
+	for i := 0; i < 500; i++ {
+		tree.InsertNoReplace(tagged{Int(rand.Intn(1000)), i})
+	}
+	sorted := tree.ToSlice()
+	for i := 0; i < 200; i++ {
+		cutoff := rand.Intn(1100) - 50
+		calls := 0
+		pred := func(i Item) bool {
+			calls++
+			return int(i.(tagged).key) >= cutoff
+		}
+		var expected Item
+		for _, item := range sorted {
+			if int(item.(tagged).key) >= cutoff {
+				expected = item
+				break
+			}
+		}
+		if got := tree.FindFirst(pred); got != expected {
+			t.Fatalf("cutoff %d: expected %v, got %v", cutoff, expected, got)
+		}
+		if calls > tree.Height() {
+			t.Errorf("cutoff %d: pred called %d times in a tree of height %d", cutoff, calls, tree.Height())
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	abs := func(x Float32) Float32 {
+		if x < 0 {
+			return -x
+		}
+		return x
+	}
+	calls := 0
+	closer := func(a, b, key Item) Item {
+		calls++
+		k := key.(Float32)
+		if abs(k-a.(Float32)) <= abs(b.(Float32)-k) {
+			return a
+		}
+		return b
+	}
+	tree := New(NaturalSortLessFloat)
+	if tree.Nearest(Float32(1), closer) != nil || calls != 0 {
+		t.Errorf("expected nil from an empty tree")
+	}
+	var xs []Float32
+	for i := 0; i < 200; i++ {
+		x := Float32(rand.Float64() * 100)
+		xs = append(xs, x)
+		tree.ReplaceOrInsert(x)
+	}
+	for i := 0; i < 1000; i++ {
+		key := Float32(rand.Float64()*120 - 10)
+		best := xs[0]
+		for _, x := range xs {
+			if d, e := abs(x-key), abs(best-key); d < e || d == e && x < best {
+				best = x
+			}
+		}
+		if got := tree.Nearest(key, closer); got != best {
+			t.Fatalf("Nearest(%v) = %v, expected %v", key, got, best)
+		}
+	}
+	calls = 0
+	if got := tree.Nearest(xs[7], closer); got != xs[7] || calls != 0 {
+		t.Errorf("expected an exact match without calling closer, got %v", got)
+	}
+	if got := tree.Nearest(Float32(-1), closer); got != tree.Min() || calls != 0 {
+		t.Errorf("expected the minimum without calling closer, got %v", got)
+	}
+	if got := tree.Nearest(Float32(1000), closer); got != tree.Max() || calls != 0 {
+		t.Errorf("expected the maximum without calling closer, got %v", got)
+	}
+}
+
+func TestFloorDuplicates(t *testing.T) {
+	tree := New(lessTagged)
+	for _, i := range rand.Perm(30) {
+		tree.ReplaceOrInsert(tagged{Int(2 * i), 0})
+	}
+	for d := 1; d <= 3; d++ {
+		tree.InsertNoReplace(tagged{Int(10), d})
+	}
+	for _, key := range []Int{10, 11} {
+		if f := tree.Floor(tagged{key, -1}); f != (tagged{Int(10), 3}) {
+			t.Errorf("Floor(%d) = %v, expected the last of the equal items", key, f)
+		}
+	}
+	if f := tree.Floor(tagged{Int(-1), -1}); f != nil {
+		t.Errorf("Floor below the minimum = %v, expected nil", f)
+	}
+	key := Item(tagged{Int(25), -1})
+	if allocs := testing.AllocsPerRun(100, func() { tree.Floor(key) }); allocs != 0 {
+		t.Errorf("Floor allocated %.1f times", allocs)
+	}
+}
+
+func TestCeilingRing(t *testing.T) {
+	// A consistent-hash ring: each key maps to the first node at or after it,
+	// wrapping around to the smallest node.
+	ring := New(lessTagged)
+	lookup := func(key Int) Item {
+		if c := ring.Ceiling(tagged{key, -1}); c != nil {
+			return c
+		}
+		return ring.Min()
+	}
+	if lookup(5) != nil {
+		t.Errorf("expected no node on an empty ring")
+	}
+	for node, pos := range []Int{100, 200, 300} {
+		ring.InsertNoReplace(tagged{pos, node})
+	}
+	ring.InsertNoReplace(tagged{Int(200), 3})
+	tests := []struct {
+		key  Int
+		node int
+	}{
+		{50, 0}, {100, 0}, {150, 1}, {200, 1}, {300, 2}, {301, 0},
+	}
+	for _, test := range tests {
+		if n := lookup(test.key).(tagged).tag; n != test.node {
+			t.Errorf("key %d maps to node %d, expected %d", test.key, n, test.node)
+		}
+	}
+}
+
+func TestSuccessorPredecessor(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 1; i <= 5; i++ {
+		tree.ReplaceOrInsert(Int(i * 10))
+	}
+	tests := []struct {
+		key        Int
+		pred, succ Item
+	}{
+		{5, nil, Int(10)},
+		{10, nil, Int(20)},
+		{25, Int(20), Int(30)},
+		{30, Int(20), Int(40)},
+		{50, Int(40), nil},
+		{55, Int(50), nil},
+	}
+	for _, test := range tests {
+		if p := tree.Predecessor(test.key); p != test.pred {
+			t.Errorf("Predecessor(%d) = %v, expected %v", test.key, p, test.pred)
+		}
+		if s := tree.Successor(test.key); s != test.succ {
+			t.Errorf("Successor(%d) = %v, expected %v", test.key, s, test.succ)
+		}
+	}
+}
+
+func cmpInt(a, b interface{}) int {
+	return int(a.(Int)) - int(b.(Int))
+}
+
+func TestLowerHigherDuplicates(t *testing.T) {
+	tree := New(lessTagged)
+	for _, i := range rand.Perm(10) {
+		tree.ReplaceOrInsert(tagged{Int(i), 0})
+	}
+	for d := 1; d <= 3; d++ {
+		tree.InsertNoReplace(tagged{Int(0), d})
+		tree.InsertNoReplace(tagged{Int(5), d})
+		tree.InsertNoReplace(tagged{Int(9), d})
+	}
+	key := func(i Item) interface{} {
+		if i == nil {
+			return nil
+		}
+		return i.(tagged).key
+	}
+	tests := []struct {
+		key           Int
+		lower, higher interface{}
+	}{
+		{0, nil, Int(1)},
+		{5, Int(4), Int(6)},
+		{9, Int(8), nil},
+	}
+	for _, test := range tests {
+		if l := key(tree.Lower(tagged{test.key, -1})); l != test.lower {
+			t.Errorf("Lower(%d) = %v, expected %v", test.key, l, test.lower)
+		}
+		if h := key(tree.Higher(tagged{test.key, -1})); h != test.higher {
+			t.Errorf("Higher(%d) = %v, expected %v", test.key, h, test.higher)
+		}
+	}
+	// Stepping with Higher visits each distinct key once.
+	var keys []Item
+	for i := tree.Min(); i != nil; i = tree.Higher(i) {
+		keys = append(keys, i.(tagged).key)
+	}
+	if len(keys) != 10 {
+		t.Errorf("stepped through %v, expected the 10 distinct keys", keys)
+	}
+}
+
+func TestNewCmp(t *testing.T) {
+	tree := NewCmp(cmpInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.InsertNoReplace(Int(i))
+	}
+	for _, i := range rand.Perm(n) {
+		if replaced := tree.ReplaceOrInsert(Int(i)); replaced != Int(i) {
+			t.Errorf("expected to replace %d, got %v", i, replaced)
+		}
+	}
+	if tree.Min() != Int(0) || tree.Max() != Int(n-1) {
+		t.Errorf("unexpected bounds %v, %v", tree.Min(), tree.Max())
+	}
+	for _, i := range rand.Perm(n) {
+		if u := tree.Delete(Int(i)); u != Int(i) {
+			t.Fatalf("delete %d failed: got %v", i, u)
+		}
+		if tree.Has(Int(i)) {
+			t.Fatalf("found %d after delete", i)
+		}
+	}
+	if tree.Len() != 0 {
+		t.Errorf("expected empty tree, len %d", tree.Len())
+	}
+}
+
+func benchmarkGetLongStrings(b *testing.B, tree *LLRB, calls *int) {
+	b.StopTimer()
+	prefix := strings.Repeat("x", 256)
+	n := 100000
+	keys := make([]String, n)
+	for i := range keys {
+		keys[i] = String(fmt.Sprintf("%s%08d", prefix, i))
+		tree.ReplaceOrInsert(keys[i])
+	}
+	*calls = 0
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Get(keys[i%n])
+	}
+	b.ReportMetric(float64(*calls)/float64(b.N), "cmps/op")
+}
+
+func BenchmarkGetLongStringsLess(b *testing.B) {
+	calls := 0
+	tree := New(func(a, b interface{}) bool {
+		calls++
+		return a.(String) < b.(String)
+	})
+	benchmarkGetLongStrings(b, tree, &calls)
+}
+
+func BenchmarkGetLongStringsCmp(b *testing.B) {
+	calls := 0
+	tree := NewCmp(func(a, b interface{}) int {
+		calls++
+		return strings.Compare(string(a.(String)), string(b.(String)))
+	})
+	benchmarkGetLongStrings(b, tree, &calls)
+}
+
+func TestClone(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	clone := tree.Clone()
+	for i := 0; i < n; i += 2 {
+		clone.Delete(Int(i))
+	}
+	clone.ReplaceOrInsert(Int(n))
+	if tree.Len() != n {
+		t.Errorf("expected original len %d, got %d", n, tree.Len())
+	}
+	for i := 0; i < n; i++ {
+		if !tree.Has(Int(i)) {
+			t.Fatalf("original lost key %d", i)
+		}
+	}
+	if tree.Has(Int(n)) {
+		t.Errorf("insert into clone leaked into original")
+	}
+	if clone.Len() != n/2+1 {
+		t.Errorf("expected clone len %d, got %d", n/2+1, clone.Len())
+	}
+}
+
+func collectAll(tree *LLRB) []Item {
+	var ary []Item
+	tree.Ascend(func(i Item) bool {
+		ary = append(ary, i)
+		return true
+	})
+	return ary
+}
+
+func TestSnapshot(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	before := collectAll(tree)
+	a := tree.Snapshot()
+	b := a.Snapshot()
+	a.ReplaceOrInsert(Int(-1))
+	b.ReplaceOrInsert(Int(n))
+	for i := 0; i < n; i += 3 {
+		tree.Delete(Int(i))
+	}
+	tree.DeleteMin()
+	tree.DeleteMax()
+
+	if !a.Has(Int(-1)) || a.Has(Int(n)) || a.Len() != n+1 {
+		t.Errorf("snapshot a diverged incorrectly")
+	}
+	if !b.Has(Int(n)) || b.Has(Int(-1)) || b.Len() != n+1 {
+		t.Errorf("snapshot b diverged incorrectly")
+	}
+	b.Delete(Int(n))
+	if !reflect.DeepEqual(collectAll(b), before) {
+		t.Errorf("snapshot b does not hold the original items")
+	}
+	if tree.Has(Int(0)) || tree.Len() != n-n/3-3 {
+		t.Errorf("unexpected original tree, len %d", tree.Len())
+	}
+	checkSizes(t, tree.Root())
+	checkSizes(t, a.Root())
+	checkSizes(t, b.Root())
+}
+
+func TestSnapshotPathCopy(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1 << 12
+	for i := 0; i < n; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	snap := tree.Snapshot()
+	tree.ReplaceOrInsert(Int(n))
+	copied := 0
+	var count func(h *Node)
+	count = func(h *Node) {
+		if h == nil {
+			return
+		}
+		if h.owner == tree.owner {
+			copied++
+		}
+		count(h.Left)
+		count(h.Right)
+	}
+	count(tree.Root())
+	if copied > 4*12 {
+		t.Errorf("insert after snapshot copied %d nodes", copied)
+	}
+	if snap.Len() != n || snap.Has(Int(n)) {
+		t.Errorf("snapshot changed by insert into original")
+	}
+}
+
+// checkInvariants fails the test if tree.Validate reports an error.
+func checkInvariants(t *testing.T, tree *LLRB) {
+	if err := tree.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAscendSnapshotDelete(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	j := 100
+	tree.AscendSnapshot(Int(100), func(i Item) bool {
+		if i != Int(j) {
+			t.Fatalf("expected %d, got %v", j, i)
+		}
+		if j%2 == 0 {
+			tree.Delete(i)
+		}
+		j++
+		return true
+	})
+	if j != n {
+		t.Errorf("expected the traversal to end at %d, got %d", n, j)
+	}
+	checkInvariants(t, tree)
+	if tree.Len() != 100+(n-100)/2 {
+		t.Errorf("unexpected len %d", tree.Len())
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	tree.InsertNoReplace(Int(500))
+	expected := tree.Between(Int(300), Int(700))
+	if items := tree.DeleteRange(Int(300), Int(700)); len(items) != 401 || !reflect.DeepEqual(items, expected) {
+		t.Errorf("expected %d items deleted in order, got %d", len(expected), len(items))
+	}
+	if items := tree.DeleteRange(Int(300), Int(700)); len(items) != 0 {
+		t.Errorf("expected nothing left to delete, got %v", items)
+	}
+	if k := tree.DeleteRangeCount(Int(300), Int(700)); k != 0 {
+		t.Errorf("expected nothing left to delete, got %d", k)
+	}
+	if tree.Len() != n-400 {
+		t.Errorf("expected len %d, got %d", n-400, tree.Len())
+	}
+	checkInvariants(t, tree)
+	j := 0
+	tree.Ascend(func(i Item) bool {
+		if j == 300 {
+			j = 700
+		}
+		if i != Int(j) {
+			t.Fatalf("expected %d, got %v", j, i)
+		}
+		j++
+		return true
+	})
+}
+
+func TestDeleteRangeEdges(t *testing.T) {
+	newTree := func() *LLRB {
+		tree := New(lessTagged)
+		for i := 0; i < 300; i++ {
+			tree.InsertNoReplace(tagged{Int(i % 30), i})
+		}
+		return tree
+	}
+	tree := newTree()
+	if items := tree.DeleteRange(tagged{10, 0}, tagged{10, 0}); len(items) != 0 || tree.Len() != 300 {
+		t.Errorf("expected an empty range to delete nothing, got %v", items)
+	}
+	// Every copy of a key on either boundary is in or out together.
+	items := tree.DeleteRange(tagged{10, 0}, tagged{20, 0})
+	if len(items) != 100 || tree.Len() != 200 {
+		t.Errorf("expected 100 items deleted, got %d, leaving %d", len(items), tree.Len())
+	}
+	for _, i := range items {
+		if k := i.(tagged).key; k < 10 || k >= 20 {
+			t.Errorf("deleted %v outside the range", i)
+		}
+	}
+	if tree.Count(tagged{9, 0}) != 10 || tree.Count(tagged{20, 0}) != 10 {
+		t.Errorf("expected the boundary keys outside the range to be kept")
+	}
+	checkInvariants(t, tree)
+	if k := tree.DeleteRangeCount(tagged{25, 0}, Inf(1)); k != 50 || tree.Len() != 150 {
+		t.Errorf("expected 50 items deleted, got %d", k)
+	}
+	checkInvariants(t, tree)
+	if items := tree.DeleteRange(Inf(-1), Inf(1)); len(items) != 150 || tree.Len() != 0 {
+		t.Errorf("expected the whole tree deleted, got %d, leaving %d", len(items), tree.Len())
+	}
+	tree = newTree()
+	if k := tree.DeleteRangeCount(Inf(-1), Inf(1)); k != 300 || tree.Len() != 0 {
+		t.Errorf("expected the whole tree deleted, got %d, leaving %d", k, tree.Len())
+	}
+}
+
+func TestReplacePayload(t *testing.T) {
+	tree := New(lessTagged)
+	for _, k := range rand.Perm(100) {
+		tree.ReplaceOrInsert(tagged{Int(k), 0})
+	}
+	for _, k := range rand.Perm(100) {
+		old := tree.ReplaceOrInsert(tagged{Int(k), k + 1})
+		if old != (tagged{Int(k), 0}) {
+			t.Errorf("expected to replace %v, got %v", tagged{Int(k), 0}, old)
+		}
+	}
+	if tree.Len() != 100 {
+		t.Errorf("expected len 100, got %d", tree.Len())
+	}
+	for k := 0; k < 100; k++ {
+		if got := tree.Get(tagged{Int(k), -1}); got != (tagged{Int(k), k + 1}) {
+			t.Errorf("expected the new payload %v, got %v", tagged{Int(k), k + 1}, got)
+		}
+	}
+}
+
+func TestMode234(t *testing.T) {
+	tree := NewWithMode(NaturalSortLessInt, Mode234)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.InsertNoReplace(Int(i))
+		tree.InsertNoReplace(Int(i))
+	}
+	checkInvariants(t, tree)
+	if tree.Len() != 2*n {
+		t.Fatalf("expected len %d, got %d", 2*n, tree.Len())
+	}
+
+	tree = NewWithMode(NaturalSortLessInt, Mode234)
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	checkInvariants(t, tree)
+	for _, i := range rand.Perm(n) {
+		if tree.ReplaceOrInsert(Int(i)) == nil {
+			t.Fatalf("expected to replace %d", i)
+		}
+	}
+	for k, i := range rand.Perm(n / 2) {
+		if tree.Delete(Int(n/4+i)) == nil {
+			t.Fatalf("delete %d failed", n/4+i)
+		}
+		if k%50 == 0 {
+			checkInvariants(t, tree)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		tree.DeleteMin()
+		tree.DeleteMax()
+		checkInvariants(t, tree)
+	}
+	if tree.Len() != n/2-20 {
+		t.Errorf("expected len %d, got %d", n/2-20, tree.Len())
+	}
+	if c := tree.Clone(); c.mode != Mode234 {
+		t.Errorf("clone lost the tree's mode")
+	}
+}
+
+func benchmarkInsertMode(b *testing.B, mode Mode) {
+	b.StopTimer()
+	perm := rand.Perm(b.N)
+	tree := NewWithMode(NaturalSortLessInt, mode)
+	b.StartTimer()
+	for _, i := range perm {
+		tree.ReplaceOrInsert(Int(i))
+	}
+}
+
+func BenchmarkInsertMode23(b *testing.B) { benchmarkInsertMode(b, Mode23) }
+
+func BenchmarkInsertMode234(b *testing.B) { benchmarkInsertMode(b, Mode234) }
+
+// deleteMinRecursive and deleteRecursive are the recursive versions of
+// deleteMin and delete, which must behave exactly the same.
+func deleteMinRecursive(t *LLRB, h *Node) (*Node, Item) {
+	if h == nil {
+		return nil, nil
+	}
+	if h.Left == nil {
+		return nil, h.Item
+	}
+
+	h = t.mutable(h)
+	if !isRed(h.Left) && !isRed(h.Left.Left) {
+		h = moveRedLeft(t, h)
+	}
+
+	var deleted Item
+	h.Left, deleted = deleteMinRecursive(t, h.Left)
+
+	return fixUp(t, h), deleted
+}
+
+func deleteRecursive(t *LLRB, h *Node, item Item, seq uint64) (*Node, Item) {
+	var deleted Item
+	if h == nil {
+		return nil, nil
+	}
+	h = t.mutable(h)
+	c := t.compareNode(item, seq, h)
+	if c < 0 {
+		if h.Left == nil { // item not present. Nothing to delete
+			return h, nil
+		}
+		if !isRed(h.Left) && !isRed(h.Left.Left) {
+			h = moveRedLeft(t, h)
+		}
+		h.Left, deleted = deleteRecursive(t, h.Left, item, seq)
+	} else {
+		// Rotations below change h.Item, in which case c is recomputed.
+		// From the above, @item is never less than the new @h.Item.
+		// A 2-3-4 4-node already has a red right link and is left alone.
+		if isRed(h.Left) && !isRed(h.Right) {
+			h = rotateRight(t, h)
+			c = t.compareNode(item, seq, h)
+		}
+		// If @item equals @h.Item and no right children at @h
+		if c == 0 && h.Right == nil {
+			return nil, h.Item
+		}
+		// PETAR: Added 'h.Right != nil' below
+		if h.Right != nil && !isRed(h.Right) && !isRed(h.Right.Left) {
+			if x := moveRedRight(t, h); x != h {
+				h = x
+				c = 1
+			}
+		}
+		// If @item equals @h.Item, and (from above) 'h.Right != nil'
+		if c == 0 {
+			// The successor's sequence number moves along with its item.
+			m := h.Right
+			for m.Left != nil {
+				m = m.Left
+			}
+			subSeq := m.seq
+			var subDeleted Item
+			h.Right, subDeleted = deleteMinRecursive(t, h.Right)
+			if subDeleted == nil {
+				panic("logic")
+			}
+			deleted, h.Item, h.seq = h.Item, subDeleted, subSeq
+		} else { // Else, @item is bigger than @h.Item
+			h.Right, deleted = deleteRecursive(t, h.Right, item, seq)
+		}
+	}
+
+	return fixUp(t, h), deleted
+}
+
+func sameNodes(a, b *Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Item == b.Item && a.Black == b.Black && a.size == b.size && a.seq == b.seq &&
+		sameNodes(a.Left, b.Left) && sameNodes(a.Right, b.Right)
+}
+
+func TestDeleteMatchesRecursive(t *testing.T) {
+	for _, tree := range []*LLRB{New(NaturalSortLessInt), NewWithMode(NaturalSortLessInt, Mode234), NewStable(NaturalSortLessInt)} {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 2000; i++ {
+			if tree.stable {
+				tree.InsertNoReplace(Int(r.Intn(1000)))
+			} else {
+				tree.ReplaceOrInsert(Int(r.Intn(1000)))
+			}
+		}
+		ref := tree.Clone()
+		for op := 0; op < 3000; op++ {
+			var got, want Item
+			if op%3 == 0 {
+				got = tree.DeleteMin()
+				ref.root, want = deleteMinRecursive(ref, ref.root)
+			} else {
+				key := Int(r.Intn(1100))
+				var seq uint64
+				if tree.stable {
+					seq = tree.oldest(key)
+				}
+				got = tree.Delete(key)
+				ref.root, want = deleteRecursive(ref, ref.root, key, seq)
+			}
+			if ref.root != nil {
+				ref.root.Black = true
+			}
+			if got != want {
+				t.Fatalf("op %d: deleted %v, expected %v", op, got, want)
+			}
+			if !sameNodes(tree.root, ref.root) {
+				t.Fatalf("op %d: trees differ", op)
+			}
+		}
+	}
+}
+
+func BenchmarkDeleteMinN(b *testing.B) {
+	items := benchmarkSortedItems(b)
+	for _, n := range []int{100, 10000, 50000} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				tree := NewFromSorted(NaturalSortLessInt, items)
+				b.StartTimer()
+				tree.DeleteMinN(n)
+			}
+		})
+	}
+}
+
+func BenchmarkDeleteMinLoop(b *testing.B) {
+	items := benchmarkSortedItems(b)
+	for _, n := range []int{100, 10000, 50000} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				tree := NewFromSorted(NaturalSortLessInt, items)
+				b.StartTimer()
+				for k := 0; k < n; k++ {
+					tree.DeleteMin()
+				}
+			}
+		})
+	}
+}
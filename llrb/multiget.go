@@ -0,0 +1,67 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import "sort"
+
+// multiGetMin is the number of keys below which MultiGet calls Get for each
+// key rather than sorting them.
+const multiGetMin = 8
+
+// MultiGet returns, for each of keys, the element that Get would return for
+// it, or nil if there is none. The keys are sorted and then looked up together
+// in one descent that splits them among the subtrees, so that the nodes near
+// the root are compared with each key only once. Keys that are already in
+// ascending order are not sorted again, which saves most of the comparisons.
+func (t *LLRB) MultiGet(keys []Item) []Item {
+	found := make([]Item, len(keys))
+	if t.root == nil {
+		return found
+	}
+	if len(keys) < multiGetMin {
+		for i, key := range keys {
+			found[i] = t.Get(key)
+		}
+		return found
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	byKey := func(i, j int) bool {
+		return less(t.comp, keys[order[i]], keys[order[j]])
+	}
+	if !sort.SliceIsSorted(order, byKey) {
+		sort.Slice(order, byKey)
+	}
+	t.multiGet(t.root, keys, order, found)
+	return found
+}
+
+// multiGet looks up below h the keys indexed by order, which are in ascending
+// order, and stores the elements found at the same indices of found.
+func (t *LLRB) multiGet(h *Node, keys []Item, order []int, found []Item) {
+	for h != nil && len(order) > 0 {
+		if len(order) == 1 {
+			if x := t.find(h, keys[order[0]]); x != nil {
+				found[order[0]] = x.Item
+			}
+			return
+		}
+		lo := sort.Search(len(order), func(i int) bool {
+			return !less(t.comp, keys[order[i]], h.Item)
+		})
+		hi := lo
+		for hi < len(order) && !less(t.comp, h.Item, keys[order[hi]]) {
+			hi++
+		}
+		for _, i := range order[lo:hi] {
+			found[i] = h.Item
+		}
+		t.multiGet(h.Left, keys, order[:lo], found)
+		order = order[hi:]
+		h = h.Right
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestMultiGet(t *testing.T) {
+	tree := New(lessTagged)
+	for _, i := range rand.Perm(1000) {
+		tree.ReplaceOrInsert(tagged{Int(2 * i), i})
+	}
+	for _, n := range []int{0, 1, multiGetMin - 1, multiGetMin, 100, 5000} {
+		keys := make([]Item, n)
+		for i := range keys {
+			keys[i] = tagged{Int(rand.Intn(2100) - 50), -1}
+		}
+		for pass := 0; pass < 2; pass++ {
+			// The second pass looks up the same keys in ascending order.
+			if pass == 1 {
+				sort.Slice(keys, func(i, j int) bool { return lessTagged(keys[i], keys[j]) })
+			}
+			found := tree.MultiGet(keys)
+			if len(found) != n {
+				t.Fatalf("expected %d results, got %d", n, len(found))
+			}
+			for i, key := range keys {
+				if found[i] != tree.Get(key) {
+					t.Fatalf("MultiGet of %v = %v, Get = %v", key, found[i], tree.Get(key))
+				}
+			}
+		}
+	}
+	keys := make([]Item, 20)
+	for i := range keys {
+		keys[i] = tagged{Int(i), 0}
+	}
+	for _, i := range New(lessTagged).MultiGet(keys) {
+		if i != nil {
+			t.Errorf("found %v in an empty tree", i)
+		}
+	}
+}
+
+// benchmarkMultiGet looks up 10000 keys, half of them missing, in a tree of
+// a million items, and reports the number of comparisons made per key.
+func benchmarkMultiGet(b *testing.B, sorted bool, get func(tree *LLRB, keys []Item)) {
+	var cmps int
+	items := make([]Item, 1000000)
+	for i := range items {
+		items[i] = Int(i)
+	}
+	tree := NewFromSorted(func(a, b interface{}) bool {
+		cmps++
+		return a.(Int) < b.(Int)
+	}, items)
+	keys := make([]Item, 10000)
+	for i := range keys {
+		keys[i] = Int(rand.Intn(2 * len(items)))
+	}
+	if sorted {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].(Int) < keys[j].(Int) })
+	}
+	cmps = 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		get(tree, keys)
+	}
+	b.ReportMetric(float64(cmps)/float64(b.N*len(keys)), "cmps/key")
+}
+
+func multiGet(tree *LLRB, keys []Item) { tree.MultiGet(keys) }
+
+func multiGetLoop(tree *LLRB, keys []Item) {
+	found := make([]Item, len(keys))
+	for i, key := range keys {
+		found[i] = tree.Get(key)
+	}
+}
+
+func BenchmarkMultiGet(b *testing.B) { benchmarkMultiGet(b, false, multiGet) }
+
+func BenchmarkMultiGetLoop(b *testing.B) { benchmarkMultiGet(b, false, multiGetLoop) }
+
+func BenchmarkMultiGetSorted(b *testing.B) { benchmarkMultiGet(b, true, multiGet) }
+
+func BenchmarkMultiGetSortedLoop(b *testing.B) { benchmarkMultiGet(b, true, multiGetLoop) }
@@ -0,0 +1,64 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// AscendParallel calls fn for every element greater or equal to pivot. The
+// elements are split by rank into at most workers ranges of roughly equal
+// size, and each range is walked in its own goroutine, so fn is called
+// concurrently and in no particular order. If fn panics, the remaining workers
+// stop early and the panic is returned as an error.
+func (t *LLRB) AscendParallel(pivot Item, workers int, fn func(Item)) error {
+	if workers < 1 {
+		panic("llrb: workers must be positive")
+	}
+	defer t.walk()()
+	start := t.Rank(pivot)
+	n := t.count - start
+	if workers > n {
+		workers = n
+	}
+
+	var (
+		wg     sync.WaitGroup
+		failed int32
+		errs   = make([]error, workers)
+	)
+	for w := 0; w < workers; w++ {
+		lo, hi := start+n*w/workers, start+n*(w+1)/workers
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					atomic.StoreInt32(&failed, 1)
+					errs[w] = fmt.Errorf("llrb: panic in AscendParallel: %v", r)
+				}
+			}()
+			k := lo
+			t.ascendFrom(t.root, lo, func(i Item) bool {
+				if k == hi || atomic.LoadInt32(&failed) != 0 {
+					return false
+				}
+				k++
+				fn(i)
+				return true
+			})
+		}(w, lo, hi)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
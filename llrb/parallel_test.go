@@ -0,0 +1,59 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAscendParallel(t *testing.T) {
+	for _, n := range []int{0, 1, 3, 1000} {
+		tree := New(NaturalSortLessInt)
+		for i := 0; i < n; i++ {
+			tree.ReplaceOrInsert(Int(i))
+		}
+		for _, workers := range []int{1, 4, 16} {
+			var mu sync.Mutex
+			var got []int
+			err := tree.AscendParallel(Int(n/2), workers, func(i Item) {
+				mu.Lock()
+				got = append(got, int(i.(Int)))
+				mu.Unlock()
+			})
+			if err != nil {
+				t.Fatalf("n=%d workers=%d: unexpected error %v", n, workers, err)
+			}
+			sort.Ints(got)
+			if len(got) != n-n/2 {
+				t.Fatalf("n=%d workers=%d: visited %d items, expected %d", n, workers, len(got), n-n/2)
+			}
+			for k, i := range got {
+				if i != n/2+k {
+					t.Fatalf("n=%d workers=%d: item %d visited in place of %d", n, workers, i, n/2+k)
+				}
+			}
+		}
+	}
+}
+
+func TestAscendParallelPanic(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 1000; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	err := tree.AscendParallel(Inf(-1), 4, func(i Item) {
+		if i.(Int) == 600 {
+			panic("boom")
+		}
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected the worker's panic as an error, got %v", err)
+	}
+	// The traversal is over, so the tree can be modified again.
+	tree.Delete(Int(600))
+}
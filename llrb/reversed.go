@@ -0,0 +1,69 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+// ReversedView presents a tree in descending order. Its methods are those of
+// the tree with the order mirrored, so that Min returns the tree's maximum and
+// Ascend visits the tree from its maximum down. Modifications through the
+// view apply to the tree.
+type ReversedView struct {
+	t *LLRB
+}
+
+// Reversed returns a view of the tree in descending order.
+func (t *LLRB) Reversed() *ReversedView { return &ReversedView{t} }
+
+// Tree returns the underlying tree.
+func (r *ReversedView) Tree() *LLRB { return r.t }
+
+func (r *ReversedView) Len() int                       { return r.t.Len() }
+func (r *ReversedView) Has(key Item) bool              { return r.t.Has(key) }
+func (r *ReversedView) Get(key Item) Item              { return r.t.Get(key) }
+func (r *ReversedView) Min() Item                      { return r.t.Max() }
+func (r *ReversedView) Max() Item                      { return r.t.Min() }
+func (r *ReversedView) Floor(key Item) Item            { return r.t.Ceiling(key) }
+func (r *ReversedView) Ceiling(key Item) Item          { return r.t.Floor(key) }
+func (r *ReversedView) Successor(key Item) Item        { return r.t.Predecessor(key) }
+func (r *ReversedView) Predecessor(key Item) Item      { return r.t.Successor(key) }
+func (r *ReversedView) ReplaceOrInsert(item Item) Item { return r.t.ReplaceOrInsert(item) }
+func (r *ReversedView) InsertNoReplace(item Item)      { r.t.InsertNoReplace(item) }
+func (r *ReversedView) Delete(key Item) Item           { return r.t.Delete(key) }
+func (r *ReversedView) DeleteMin() Item                { return r.t.DeleteMax() }
+func (r *ReversedView) DeleteMax() Item                { return r.t.DeleteMin() }
+func (r *ReversedView) ToSlice() []Item                { return r.t.ToSliceDescending() }
+func (r *ReversedView) Ascend(iterator ItemIterator)   { r.t.Descend(iterator) }
+func (r *ReversedView) Descend(iterator ItemIterator)  { r.t.Ascend(iterator) }
+
+func (r *ReversedView) AscendGreaterOrEqual(pivot Item, iterator ItemIterator) {
+	r.t.DescendLessOrEqual(pivot, iterator)
+}
+
+func (r *ReversedView) AscendGreaterThan(pivot Item, iterator ItemIterator) {
+	r.t.DescendLessThan(pivot, iterator)
+}
+
+func (r *ReversedView) AscendLessThan(pivot Item, iterator ItemIterator) {
+	r.t.DescendGreaterThan(pivot, iterator)
+}
+
+func (r *ReversedView) AscendRange(greaterOrEqual, lessThan Item, iterator ItemIterator) {
+	r.t.DescendRange(greaterOrEqual, lessThan, iterator)
+}
+
+func (r *ReversedView) DescendLessOrEqual(pivot Item, iterator ItemIterator) {
+	r.t.AscendGreaterOrEqual(pivot, iterator)
+}
+
+func (r *ReversedView) DescendLessThan(pivot Item, iterator ItemIterator) {
+	r.t.AscendGreaterThan(pivot, iterator)
+}
+
+func (r *ReversedView) DescendGreaterThan(pivot Item, iterator ItemIterator) {
+	r.t.AscendLessThan(pivot, iterator)
+}
+
+func (r *ReversedView) DescendRange(lessOrEqual, greaterThan Item, iterator ItemIterator) {
+	r.t.AscendRange(lessOrEqual, greaterThan, iterator)
+}
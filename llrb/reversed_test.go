@@ -0,0 +1,85 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReversedView(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 10; i++ {
+		tree.ReplaceOrInsert(Int(i * 10))
+	}
+	r := tree.Reversed()
+	collect := func(f func(ItemIterator)) []Item {
+		var items []Item
+		f(func(i Item) bool {
+			items = append(items, i)
+			return true
+		})
+		return items
+	}
+	ints := func(is ...int) []Item {
+		var items []Item
+		for _, i := range is {
+			items = append(items, Int(i))
+		}
+		return items
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"Len", r.Len(), 10},
+		{"Has", r.Has(Int(30)), true},
+		{"Get", r.Get(Int(30)), Int(30)},
+		{"Min", r.Min(), Int(90)},
+		{"Max", r.Max(), Int(0)},
+		{"Floor", r.Floor(Int(35)), Int(40)},
+		{"Ceiling", r.Ceiling(Int(35)), Int(30)},
+		{"Successor", r.Successor(Int(30)), Int(20)},
+		{"Predecessor", r.Predecessor(Int(30)), Int(40)},
+		{"ToSlice", r.ToSlice(), ints(90, 80, 70, 60, 50, 40, 30, 20, 10, 0)},
+		{"Ascend", collect(r.Ascend), ints(90, 80, 70, 60, 50, 40, 30, 20, 10, 0)},
+		{"Descend", collect(r.Descend), ints(0, 10, 20, 30, 40, 50, 60, 70, 80, 90)},
+		{"AscendGreaterOrEqual", collect(func(f ItemIterator) { r.AscendGreaterOrEqual(Int(20), f) }), ints(20, 10, 0)},
+		{"AscendGreaterThan", collect(func(f ItemIterator) { r.AscendGreaterThan(Int(20), f) }), ints(10, 0)},
+		{"AscendLessThan", collect(func(f ItemIterator) { r.AscendLessThan(Int(70), f) }), ints(90, 80)},
+		{"AscendRange", collect(func(f ItemIterator) { r.AscendRange(Int(50), Int(20), f) }), ints(50, 40, 30)},
+		{"DescendLessOrEqual", collect(func(f ItemIterator) { r.DescendLessOrEqual(Int(70), f) }), ints(70, 80, 90)},
+		{"DescendLessThan", collect(func(f ItemIterator) { r.DescendLessThan(Int(70), f) }), ints(80, 90)},
+		{"DescendGreaterThan", collect(func(f ItemIterator) { r.DescendGreaterThan(Int(20), f) }), ints(0, 10)},
+		{"DescendRange", collect(func(f ItemIterator) { r.DescendRange(Int(30), Int(60), f) }), ints(30, 40, 50)},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: got %v, expected %v", test.name, test.got, test.want)
+		}
+	}
+
+	mutations := []struct {
+		name      string
+		got, want Item
+	}{
+		{"DeleteMin", r.DeleteMin(), Int(90)},
+		{"DeleteMax", r.DeleteMax(), Int(0)},
+		{"Delete", r.Delete(Int(50)), Int(50)},
+		{"ReplaceOrInsert", r.ReplaceOrInsert(Int(55)), nil},
+	}
+	for _, test := range mutations {
+		if test.got != test.want {
+			t.Errorf("%s: got %v, expected %v", test.name, test.got, test.want)
+		}
+	}
+	r.InsertNoReplace(Int(55))
+	if r.Len() != tree.Len() || tree.Len() != 9 {
+		t.Errorf("view len %d and tree len %d, expected 9", r.Len(), tree.Len())
+	}
+	if r.Tree() != tree || tree.Min() != Int(10) || tree.Max() != Int(80) {
+		t.Errorf("mutations through the view did not apply to the tree")
+	}
+}
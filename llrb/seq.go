@@ -0,0 +1,31 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package llrb
+
+import "iter"
+
+// All returns a sequence of every element in the tree, in ascending order.
+func (t *LLRB) All() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		t.Ascend(yield)
+	}
+}
+
+// Backward returns a sequence of every element in the tree, in descending order.
+func (t *LLRB) Backward() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		t.Descend(yield)
+	}
+}
+
+// RangeSeq returns a sequence of the elements greater or equal to lo and
+// less than hi, in ascending order.
+func (t *LLRB) RangeSeq(lo, hi Item) iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		t.AscendRange(lo, hi, yield)
+	}
+}
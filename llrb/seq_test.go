@@ -0,0 +1,55 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package llrb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeq(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for item := range tree.All() {
+		t.Errorf("visited %v in empty tree", item)
+	}
+	for i := 0; i < 10; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	for pass := 0; pass < 2; pass++ {
+		var ary []Item
+		for item := range tree.All() {
+			ary = append(ary, item)
+		}
+		if len(ary) != 10 || ary[0] != Int(0) || ary[9] != Int(9) {
+			t.Errorf("pass %d: unexpected All sequence %v", pass, ary)
+		}
+	}
+	var ary []Item
+	for item := range tree.Backward() {
+		if item == Int(6) {
+			break
+		}
+		ary = append(ary, item)
+	}
+	expected := []Item{Int(9), Int(8), Int(7)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	ary = nil
+	for item := range tree.RangeSeq(Int(3), Int(6)) {
+		ary = append(ary, item)
+	}
+	expected = []Item{Int(3), Int(4), Int(5)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+	// Breaking out of a sequence ends the traversal, so the tree is mutable again.
+	for range tree.All() {
+		break
+	}
+	tree.Delete(Int(0))
+}
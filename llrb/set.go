@@ -0,0 +1,165 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+// The set operations below walk two trees together in ascending order. Both
+// trees must be ordered the same way, and the receiver's comparison function
+// is used for both. Since functions cannot be compared, this is checked as
+// the other tree is walked: finding its items out of order panics.
+
+// Union returns a new tree holding the items of both t and other. If an item
+// of other has the same order as an item of t, only the item of t is kept, so
+// the result holds every item of t, and the items of other whose order t
+// lacks.
+func (t *LLRB) Union(other *LLRB) *LLRB {
+	var items []Item
+	t.coWalk(other, func(x, y Item) {
+		switch {
+		case x != nil:
+			items = append(items, x)
+		case y != nil:
+			items = append(items, y)
+		}
+	})
+	return t.fromSorted(items)
+}
+
+// Intersection returns a new tree holding the items of t that have the same
+// order as an item of other.
+func (t *LLRB) Intersection(other *LLRB) *LLRB {
+	var items []Item
+	t.coWalk(other, func(x, y Item) {
+		if x != nil && y != nil {
+			items = append(items, x)
+		}
+	})
+	return t.fromSorted(items)
+}
+
+// Difference returns a new tree holding the items of t that do not have the
+// same order as any item of other.
+func (t *LLRB) Difference(other *LLRB) *LLRB {
+	var items []Item
+	t.coWalk(other, func(x, y Item) {
+		if x != nil && y == nil {
+			items = append(items, x)
+		}
+	})
+	return t.fromSorted(items)
+}
+
+// Equal reports whether t and other hold the same number of items and
+// itemsEqual holds for each pair of items at the same position in ascending
+// order. If itemsEqual is nil, items are equal when they have the same order.
+// It stops at the first difference.
+func (t *LLRB) Equal(other *LLRB, itemsEqual func(a, b Item) bool) bool {
+	if t.count != other.count {
+		return false
+	}
+	if itemsEqual == nil {
+		itemsEqual = func(a, b Item) bool { return t.compare(a, b) == 0 }
+	}
+	ia, ib := t.NewIterator(), other.NewIterator()
+	for ia.Next() && ib.Next() {
+		if !itemsEqual(ia.Item(), ib.Item()) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSubset reports whether every item of t has the same order as some item of
+// other. Duplicates are not counted: any number of equal items of t are
+// contained in a single equal item of other. The trees are walked together,
+// stopping at the first item of t that other lacks.
+func (t *LLRB) IsSubset(other *LLRB) bool {
+	ia, ib := t.NewIterator(), other.NewIterator()
+	okB := ib.Next()
+	for ia.Next() {
+		for okB && less(t.comp, ib.Item(), ia.Item()) {
+			okB = ib.Next()
+		}
+		if !okB || less(t.comp, ia.Item(), ib.Item()) {
+			return false
+		}
+	}
+	return true
+}
+
+// ContainsAll reports whether every one of keys has the same order as some
+// item of t. It stops at the first key that t lacks.
+func (t *LLRB) ContainsAll(keys []Item) bool {
+	for _, key := range keys {
+		if !t.Has(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// coWalk walks t and other together in ascending order. For each item of t,
+// fn is called with that item and the first item of other having the same
+// order, or nil if other has none. The other items of other having that order
+// are skipped. For each item of other whose order is missing from t, fn is
+// called with nil and that item. Equal items are thus matched by order, not by
+// position.
+func (t *LLRB) coWalk(other *LLRB, fn func(x, y Item)) {
+	ia, ib := t.NewIterator(), other.NewIterator()
+	okA, okB := ia.Next(), ib.Next()
+	var prev Item
+	nextB := func() {
+		if prev != nil && less(t.comp, ib.Item(), prev) {
+			panic("llrb: trees are ordered differently")
+		}
+		prev = ib.Item()
+		okB = ib.Next()
+	}
+	for okA || okB {
+		c := 0
+		switch {
+		case !okB:
+			c = -1
+		case !okA:
+			c = 1
+		default:
+			c = t.compare(ia.Item(), ib.Item())
+		}
+		switch {
+		case c < 0:
+			fn(ia.Item(), nil)
+			okA = ia.Next()
+		case c > 0:
+			y := ib.Item()
+			nextB()
+			fn(nil, y)
+		default:
+			x, y := ia.Item(), ib.Item()
+			for okB && t.compare(ib.Item(), x) == 0 {
+				nextB()
+			}
+			for okA && t.compare(ia.Item(), x) == 0 {
+				fn(ia.Item(), y)
+				okA = ia.Next()
+			}
+		}
+	}
+}
+
+// fromSorted returns a new tree ordered like t and holding items, which are
+// in ascending order.
+func (t *LLRB) fromSorted(items []Item) *LLRB {
+	ret := t.newLike()
+	ret.loadSorted(items)
+	return ret
+}
+
+// Split returns a new tree holding the items of t that are less than key, and
+// another holding the items that are greater or equal to key. Both are
+// ordered like t, which is left unchanged.
+func (t *LLRB) Split(key Item) (left, right *LLRB) {
+	items := t.ToSlice()
+	k := t.Rank(key)
+	return t.fromSorted(items[:k]), t.fromSorted(items[k:])
+}
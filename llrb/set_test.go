@@ -0,0 +1,215 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func taggedTree(tag int, keys ...int) *LLRB {
+	tree := New(lessTagged)
+	for _, k := range keys {
+		tree.InsertNoReplace(tagged{Int(k), tag})
+	}
+	return tree
+}
+
+func TestUnion(t *testing.T) {
+	a := taggedTree(0, 1, 3, 5, 7)
+	b := taggedTree(1, 2, 3, 4, 7, 8)
+	u := a.Union(b)
+	expected := []Item{
+		tagged{1, 0}, tagged{2, 1}, tagged{3, 0}, tagged{4, 1},
+		tagged{5, 0}, tagged{7, 0}, tagged{8, 1},
+	}
+	if items := u.ToSlice(); !reflect.DeepEqual(items, expected) {
+		t.Errorf("expected %v but got %v", expected, items)
+	}
+	if u.Len() != 7 || a.Len() != 4 || b.Len() != 5 {
+		t.Errorf("unexpected lengths %d, %d, %d", u.Len(), a.Len(), b.Len())
+	}
+	checkInvariants(t, u)
+}
+
+func TestUnionDuplicates(t *testing.T) {
+	a, b := NewStable(lessTagged), NewStable(lessTagged)
+	a.InsertNoReplaceBulk(tagged{3, 0}, tagged{5, 1}, tagged{5, 2})
+	b.InsertNoReplaceBulk(tagged{4, 9}, tagged{4, 8}, tagged{5, 9}, tagged{5, 8}, tagged{5, 7})
+	expected := []Item{tagged{3, 0}, tagged{4, 9}, tagged{4, 8}, tagged{5, 1}, tagged{5, 2}}
+	if got := a.Union(b).ToSlice(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	expected = []Item{tagged{3, 0}, tagged{4, 9}, tagged{4, 8}, tagged{5, 9}, tagged{5, 8}, tagged{5, 7}}
+	if got := b.Union(a).ToSlice(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	checkInvariants(t, a.Union(b))
+}
+
+func TestUnionMismatchedOrder(t *testing.T) {
+	a := New(NaturalSortLessInt)
+	b := New(func(x, y interface{}) bool { return x.(Int) > y.(Int) })
+	for i := 0; i < 10; i++ {
+		a.ReplaceOrInsert(Int(i))
+		b.ReplaceOrInsert(Int(i))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for trees ordered differently")
+		}
+	}()
+	a.Union(b)
+}
+
+func TestIntersectionDifference(t *testing.T) {
+	tests := []struct {
+		a, b          []int
+		inter, differ []int
+	}{
+		{[]int{1, 3, 5}, []int{2, 4, 6}, nil, []int{1, 3, 5}},
+		{[]int{1, 3, 5}, []int{1, 3, 5}, []int{1, 3, 5}, nil},
+		{[]int{1, 2, 3, 4}, []int{3, 4, 5}, []int{3, 4}, []int{1, 2}},
+		{nil, []int{1}, nil, nil},
+		{[]int{5, 5}, []int{5}, []int{5, 5}, nil},
+		{[]int{5}, []int{5, 5}, []int{5}, nil},
+		{[]int{1, 5, 5, 7}, []int{5, 5, 5, 7, 7}, []int{5, 5, 7}, []int{1}},
+		{[]int{3, 3, 5}, []int{5, 5}, []int{5}, []int{3, 3}},
+	}
+	items := func(keys []int) []Item {
+		ret := []Item{}
+		for _, k := range keys {
+			ret = append(ret, tagged{Int(k), 0})
+		}
+		return ret
+	}
+	for _, test := range tests {
+		a, b := taggedTree(0, test.a...), taggedTree(1, test.b...)
+		if got := a.Intersection(b).ToSlice(); !reflect.DeepEqual(got, items(test.inter)) {
+			t.Errorf("%v ∩ %v: expected %v, got %v", test.a, test.b, items(test.inter), got)
+		}
+		if got := a.Difference(b).ToSlice(); !reflect.DeepEqual(got, items(test.differ)) {
+			t.Errorf("%v - %v: expected %v, got %v", test.a, test.b, items(test.differ), got)
+		}
+		if subset := a.Difference(b).Len() == 0; a.IsSubset(b) != subset {
+			t.Errorf("%v - %v: IsSubset is %v, but the difference has %d items", test.a, test.b, !subset, a.Difference(b).Len())
+		}
+	}
+
+	// Every item of a having the order of some item of b is matched, not just
+	// as many as b holds.
+	a, b := NewStable(lessTagged), NewStable(lessTagged)
+	a.InsertNoReplaceBulk(tagged{5, 1}, tagged{5, 2})
+	b.InsertNoReplace(tagged{5, 9})
+	expected := []Item{tagged{5, 1}, tagged{5, 2}}
+	if got := a.Intersection(b).ToSlice(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if d := a.Difference(b); d.Len() != 0 {
+		t.Errorf("expected an empty difference, got %v", d.ToSlice())
+	}
+	if got := b.Intersection(a).ToSlice(); !reflect.DeepEqual(got, []Item{tagged{5, 9}}) {
+		t.Errorf("expected [{5 9}], got %v", got)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	tree.InsertNoReplace(Int(40))
+	left, right := tree.Split(Int(40))
+	if left.Len() != 40 || right.Len() != 61 {
+		t.Errorf("unexpected split sizes %d and %d", left.Len(), right.Len())
+	}
+	if left.Max() != Int(39) || right.Min() != Int(40) {
+		t.Errorf("unexpected split bounds %v and %v", left.Max(), right.Min())
+	}
+	checkInvariants(t, left)
+	checkInvariants(t, right)
+	joined := append(left.ToSlice(), right.ToSlice()...)
+	if !reflect.DeepEqual(joined, tree.ToSlice()) {
+		t.Errorf("left and right do not reproduce the original tree")
+	}
+	left, right = tree.Split(Inf(1))
+	if left.Len() != tree.Len() || right.Len() != 0 {
+		t.Errorf("unexpected split sizes %d and %d", left.Len(), right.Len())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	sameTag := func(a, b Item) bool { return a == b }
+	a := taggedTree(0, 1, 2, 3, 4)
+	b := taggedTree(0, 4, 3, 2, 1)
+	if !a.Equal(b, sameTag) || !b.Equal(a, nil) {
+		t.Errorf("expected equal trees")
+	}
+	if !New(lessTagged).Equal(New(lessTagged), sameTag) {
+		t.Errorf("expected empty trees to be equal")
+	}
+	// Same keys, one payload differs.
+	c := taggedTree(0, 1, 2, 4)
+	c.InsertNoReplace(tagged{3, 1})
+	if a.Equal(c, sameTag) {
+		t.Errorf("expected trees with different payloads to differ")
+	}
+	if !a.Equal(c, nil) {
+		t.Errorf("expected trees with the same keys to be equal by order")
+	}
+	// One key differs.
+	if a.Equal(taggedTree(0, 1, 2, 3, 5), nil) {
+		t.Errorf("expected trees with different keys to differ")
+	}
+	// Different sizes.
+	if a.Equal(taggedTree(0, 1, 2, 3), nil) || taggedTree(0, 1, 2, 3).Equal(a, nil) {
+		t.Errorf("expected trees of different sizes to differ")
+	}
+	calls := 0
+	a.Equal(taggedTree(0, 0, 2, 3, 4), func(x, y Item) bool {
+		calls++
+		return x == y
+	})
+	if calls != 1 {
+		t.Errorf("expected Equal to stop at the first difference, got %d calls", calls)
+	}
+}
+
+func TestIsSubset(t *testing.T) {
+	empty := taggedTree(0)
+	a := taggedTree(0, 2, 4, 6)
+	b := taggedTree(1, 1, 2, 3, 4, 5, 6)
+	cases := []struct {
+		x, y     *LLRB
+		expected bool
+	}{
+		{a, b, true},
+		{b, a, false},
+		{a, a, true},
+		{empty, a, true},
+		{empty, empty, true},
+		{a, empty, false},
+		{taggedTree(0, 2, 4, 7), b, false},
+		{taggedTree(0, 0, 2), b, false},
+		// Duplicates are contained in a single equal item.
+		{taggedTree(0, 2, 2, 2, 4), a, true},
+		{a, taggedTree(1, 2, 2, 4, 4, 6, 6), true},
+	}
+	for i, c := range cases {
+		if got := c.x.IsSubset(c.y); got != c.expected {
+			t.Errorf("case %d: %v.IsSubset(%v) = %v", i, c.x.ToSlice(), c.y.ToSlice(), got)
+		}
+	}
+	if !b.ContainsAll([]Item{tagged{6, 0}, tagged{1, 0}, tagged{6, 0}}) {
+		t.Errorf("expected b to contain all of the keys")
+	}
+	if b.ContainsAll([]Item{tagged{1, 0}, tagged{7, 0}}) {
+		t.Errorf("expected b to lack 7")
+	}
+	if !empty.ContainsAll(nil) || empty.ContainsAll([]Item{tagged{1, 0}}) {
+		t.Errorf("unexpected result from an empty tree")
+	}
+}
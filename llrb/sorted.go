@@ -0,0 +1,79 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+// NewFromSorted allocates a new tree holding items, which must be in
+// ascending order. It builds the tree in O(n) time, without the comparisons
+// and rotations of inserting the items one by one. It panics if items holds
+// nil or is out of order.
+func NewFromSorted(sortFunction Comparer, items []Item) *LLRB {
+	ret := New(sortFunction)
+	ret.loadSorted(items)
+	return ret
+}
+
+// loadSorted replaces the contents of t, which must be empty, with items in
+// ascending order. Items of the same order are kept in the order given.
+func (t *LLRB) loadSorted(items []Item) {
+	t.mutate()
+	for i, item := range items {
+		if item == nil {
+			panic("inserting nil item")
+		}
+		if i > 0 && less(t.comp, item, items[i-1]) {
+			panic("llrb: items are not sorted")
+		}
+	}
+	h := 0
+	for n := len(items); n > 1; n >>= 1 {
+		h++
+	}
+	if len(items) == 1<<uint(h+1)-1 {
+		h++
+	}
+	t.root = t.buildSorted(items, h)
+	if t.root != nil {
+		t.root.Black = true
+	}
+	t.count = len(items)
+}
+
+// buildSorted returns a 2-3 tree of black height h holding items, which
+// requires 2^h-1 <= len(items) <= 3^h-1. Each node is a 2-node if the rest of
+// the items fit under it, and a 3-node otherwise. Nodes are allocated in
+// ascending order, which keeps the sequence numbers of a stable tree in order.
+func (t *LLRB) buildSorted(items []Item, h int) *Node {
+	n := len(items)
+	if n == 0 {
+		return nil
+	}
+	max := 1 // 3^(h-1), one more than the most items below a child
+	for i := 1; i < h; i++ {
+		max *= 3
+	}
+	if m := n - 1; m <= 2*(max-1) {
+		a := m - m/2
+		left := t.buildSorted(items[:a], h-1)
+		x := newNode(t, items[a])
+		x.Left = left
+		x.Right = t.buildSorted(items[a+1:], h-1)
+		x.Black = true
+		fixSize(x)
+		return x
+	}
+	m := n - 2
+	a, b := (m+2)/3, (m+1)/3
+	left := t.buildSorted(items[:a], h-1)
+	red := newNode(t, items[a])
+	red.Left = left
+	red.Right = t.buildSorted(items[a+1:a+1+b], h-1)
+	fixSize(red)
+	x := newNode(t, items[a+1+b])
+	x.Left = red
+	x.Right = t.buildSorted(items[a+2+b:], h-1)
+	x.Black = true
+	fixSize(x)
+	return x
+}
@@ -0,0 +1,87 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNewFromSorted(t *testing.T) {
+	for n := 0; n < 300; n++ {
+		keys := make([]int, n)
+		for i := range keys {
+			keys[i] = rand.Intn(n + 1)
+		}
+		sort.Ints(keys)
+		items := make([]Item, n)
+		inserted := New(NaturalSortLessInt)
+		for i, k := range keys {
+			items[i] = Int(k)
+			inserted.InsertNoReplace(Int(k))
+		}
+		tree := NewFromSorted(NaturalSortLessInt, items)
+		checkInvariants(t, tree)
+		if !reflect.DeepEqual(tree.ToSlice(), inserted.ToSlice()) {
+			t.Fatalf("n=%d: expected %v, got %v", n, inserted.ToSlice(), tree.ToSlice())
+		}
+		// The tree must take later modifications.
+		tree.ReplaceOrInsert(Int(-1))
+		tree.DeleteMax()
+		checkInvariants(t, tree)
+	}
+}
+
+func TestNewFromSortedStable(t *testing.T) {
+	var items []Item
+	for i := 0; i < 100; i++ {
+		items = append(items, tagged{Int(i / 10), i})
+	}
+	// Set operations load their results into a tree made like the receiver.
+	tree := NewStable(lessTagged)
+	tree.loadSorted(items)
+	tree.Delete(tagged{3, 0})
+	if items := tree.GetAll(tagged{3, 0}); items[0] != (tagged{3, 31}) {
+		t.Errorf("expected the first of the equal items to be deleted, got %v", items)
+	}
+}
+
+func TestNewFromSortedPanics(t *testing.T) {
+	for _, items := range [][]Item{{Int(1), nil}, {Int(2), Int(1)}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for %v", items)
+				}
+			}()
+			NewFromSorted(NaturalSortLessInt, items)
+		}()
+	}
+}
+
+func benchmarkSortedItems(b *testing.B) []Item {
+	items := make([]Item, 100000)
+	for i := range items {
+		items[i] = Int(i)
+	}
+	b.ResetTimer()
+	return items
+}
+
+func BenchmarkNewFromSorted(b *testing.B) {
+	items := benchmarkSortedItems(b)
+	for i := 0; i < b.N; i++ {
+		NewFromSorted(NaturalSortLessInt, items)
+	}
+}
+
+func BenchmarkInsertSorted(b *testing.B) {
+	items := benchmarkSortedItems(b)
+	for i := 0; i < b.N; i++ {
+		New(NaturalSortLessInt).InsertNoReplaceBulk(items...)
+	}
+}
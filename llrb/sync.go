@@ -0,0 +1,154 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import "sync"
+
+// SyncLLRB is an LLRB that is safe for use by multiple goroutines. Reads
+// share a read lock and writes take an exclusive lock. Traversal callbacks
+// run while the read lock is held, so they must not modify the tree.
+type SyncLLRB struct {
+	mu   sync.RWMutex
+	tree *LLRB
+}
+
+// NewSync allocates a new tree that is safe for concurrent use.
+func NewSync(sortFunction Comparer) *SyncLLRB {
+	return &SyncLLRB{tree: New(sortFunction)}
+}
+
+// Len returns the number of nodes in the tree.
+func (t *SyncLLRB) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.tree.Len()
+}
+
+// Has returns true if the tree contains an element whose order is the same as that of key.
+func (t *SyncLLRB) Has(key Item) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.tree.Has(key)
+}
+
+// Get retrieves an element from the tree whose order is the same as that of key.
+func (t *SyncLLRB) Get(key Item) Item {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.tree.Get(key)
+}
+
+// Min returns the minimum element in the tree.
+func (t *SyncLLRB) Min() Item {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.tree.Min()
+}
+
+// Max returns the maximum element in the tree.
+func (t *SyncLLRB) Max() Item {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.tree.Max()
+}
+
+// ReplaceOrInsert inserts item into the tree. If an existing
+// element has the same order, it is removed from the tree and returned.
+func (t *SyncLLRB) ReplaceOrInsert(item Item) Item {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.ReplaceOrInsert(item)
+}
+
+// InsertNoReplace inserts item into the tree. If an existing
+// element has the same order, both elements remain in the tree.
+func (t *SyncLLRB) InsertNoReplace(item Item) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tree.InsertNoReplace(item)
+}
+
+// Delete deletes an item from the tree whose key equals key.
+// The deleted item is return, otherwise nil is returned.
+func (t *SyncLLRB) Delete(key Item) Item {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.Delete(key)
+}
+
+// DeleteMin deletes the minimum element in the tree and returns the
+// deleted item or nil otherwise.
+func (t *SyncLLRB) DeleteMin() Item {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.DeleteMin()
+}
+
+// DeleteMax deletes the maximum element in the tree and returns
+// the deleted item or nil otherwise
+func (t *SyncLLRB) DeleteMax() Item {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.DeleteMax()
+}
+
+// Ascend is like LLRB.Ascend, and holds the read lock throughout.
+func (t *SyncLLRB) Ascend(iterator ItemIterator) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.Ascend(iterator)
+}
+
+// Descend is like LLRB.Descend, and holds the read lock throughout.
+func (t *SyncLLRB) Descend(iterator ItemIterator) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.Descend(iterator)
+}
+
+// AscendRange is like LLRB.AscendRange, and holds the read lock throughout.
+func (t *SyncLLRB) AscendRange(greaterOrEqual, lessThan Item, iterator ItemIterator) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.AscendRange(greaterOrEqual, lessThan, iterator)
+}
+
+// AscendGreaterOrEqual is like LLRB.AscendGreaterOrEqual, and holds the read
+// lock throughout.
+func (t *SyncLLRB) AscendGreaterOrEqual(pivot Item, iterator ItemIterator) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.AscendGreaterOrEqual(pivot, iterator)
+}
+
+// AscendLessThan is like LLRB.AscendLessThan, and holds the read lock throughout.
+func (t *SyncLLRB) AscendLessThan(pivot Item, iterator ItemIterator) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.AscendLessThan(pivot, iterator)
+}
+
+// DescendLessOrEqual is like LLRB.DescendLessOrEqual, and holds the read lock
+// throughout.
+func (t *SyncLLRB) DescendLessOrEqual(pivot Item, iterator ItemIterator) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.DescendLessOrEqual(pivot, iterator)
+}
+
+// DescendGreaterThan is like LLRB.DescendGreaterThan, and holds the read lock
+// throughout.
+func (t *SyncLLRB) DescendGreaterThan(pivot Item, iterator ItemIterator) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.DescendGreaterThan(pivot, iterator)
+}
+
+// DescendRange is like LLRB.DescendRange, and holds the read lock throughout.
+func (t *SyncLLRB) DescendRange(lessOrEqual, greaterThan Item, iterator ItemIterator) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.DescendRange(lessOrEqual, greaterThan, iterator)
+}
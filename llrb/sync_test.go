@@ -0,0 +1,53 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// TestSyncConcurrent is meant to be run with -race.
+func TestSyncConcurrent(t *testing.T) {
+	tree := NewSync(NaturalSortLessInt)
+	n := 1000
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, i := range rand.Perm(n) {
+			tree.ReplaceOrInsert(Int(i))
+			if i%5 == 0 {
+				tree.Delete(Int(i))
+			}
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 200; k++ {
+				tree.Get(Int(rand.Intn(n)))
+				tree.Min()
+				tree.Len()
+				prev := Int(-1)
+				tree.AscendGreaterOrEqual(Inf(-1), func(i Item) bool {
+					if i.(Int) <= prev {
+						t.Errorf("bad order: %v after %v", i, prev)
+						return false
+					}
+					prev = i.(Int)
+					return true
+				})
+				tree.Descend(func(Item) bool { return true })
+			}
+		}()
+	}
+	wg.Wait()
+	if tree.Len() != n-n/5 {
+		t.Errorf("expected len %d, got %d", n-n/5, tree.Len())
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package llrb
+
+// Tree is a type-safe wrapper around LLRB whose items all have type T.
+// It runs the same algorithm as LLRB, so callers get compile-time checking
+// of item types without writing type assertions in their comparison function.
+type Tree[T any] struct {
+	llrb *LLRB
+}
+
+// NewTree allocates a new tree ordered by less.
+func NewTree[T any](less func(a, b T) bool) *Tree[T] {
+	return &Tree[T]{
+		llrb: New(func(a, b interface{}) bool {
+			return less(a.(T), b.(T))
+		}),
+	}
+}
+
+// Len returns the number of items in the tree.
+func (t *Tree[T]) Len() int { return t.llrb.Len() }
+
+// Has returns true if the tree contains an item whose order is the same as that of key.
+func (t *Tree[T]) Has(key T) bool { return t.llrb.Has(key) }
+
+// Get retrieves an item from the tree whose order is the same as that of key.
+// The boolean result reports whether such an item was found.
+func (t *Tree[T]) Get(key T) (T, bool) { return unwrap[T](t.llrb.Get(key)) }
+
+// Min returns the minimum item in the tree, and false if the tree is empty.
+func (t *Tree[T]) Min() (T, bool) { return unwrap[T](t.llrb.Min()) }
+
+// Max returns the maximum item in the tree, and false if the tree is empty.
+func (t *Tree[T]) Max() (T, bool) { return unwrap[T](t.llrb.Max()) }
+
+// ReplaceOrInsert inserts item into the tree. If an existing item has the
+// same order, it is removed from the tree and returned along with true.
+func (t *Tree[T]) ReplaceOrInsert(item T) (T, bool) {
+	return unwrap[T](t.llrb.ReplaceOrInsert(item))
+}
+
+// InsertNoReplace inserts item into the tree. If an existing item has the
+// same order, both items remain in the tree.
+func (t *Tree[T]) InsertNoReplace(item T) { t.llrb.InsertNoReplace(item) }
+
+// Delete deletes an item from the tree whose order is the same as that of key.
+// The deleted item is returned along with true.
+func (t *Tree[T]) Delete(key T) (T, bool) { return unwrap[T](t.llrb.Delete(key)) }
+
+// DeleteMin deletes the minimum item in the tree and returns it along with true.
+func (t *Tree[T]) DeleteMin() (T, bool) { return unwrap[T](t.llrb.DeleteMin()) }
+
+// DeleteMax deletes the maximum item in the tree and returns it along with true.
+func (t *Tree[T]) DeleteMax() (T, bool) { return unwrap[T](t.llrb.DeleteMax()) }
+
+// Ascend will call iterator once for each item in the tree, in ascending
+// order. It will stop whenever the iterator returns false.
+func (t *Tree[T]) Ascend(iterator func(item T) bool) {
+	t.llrb.Ascend(func(i Item) bool { return iterator(i.(T)) })
+}
+
+// Descend will call iterator once for each item in the tree, in descending
+// order. It will stop whenever the iterator returns false.
+func (t *Tree[T]) Descend(iterator func(item T) bool) {
+	t.llrb.Descend(func(i Item) bool { return iterator(i.(T)) })
+}
+
+func unwrap[T any](i Item) (T, bool) {
+	if i == nil {
+		var zero T
+		return zero, false
+	}
+	return i.(T), true
+}
@@ -0,0 +1,55 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package llrb
+
+import (
+	"math/rand"
+	"testing"
+)
+
+type point struct {
+	x, y float32
+}
+
+func TestTree(t *testing.T) {
+	tree := NewTree(func(a, b point) bool { return a.x < b.x })
+	if _, ok := tree.Min(); ok {
+		t.Errorf("expected no Min in empty tree")
+	}
+	for _, i := range rand.Perm(100) {
+		tree.ReplaceOrInsert(point{float32(i), 0})
+	}
+	if old, ok := tree.ReplaceOrInsert(point{5, 1}); !ok || old != (point{5, 0}) {
+		t.Errorf("expected to replace {5 0}, got %v (%v)", old, ok)
+	}
+	if p, ok := tree.Get(point{5, -1}); !ok || p != (point{5, 1}) {
+		t.Errorf("expected {5 1}, got %v (%v)", p, ok)
+	}
+	if p, ok := tree.Max(); !ok || p.x != 99 {
+		t.Errorf("expected max 99, got %v (%v)", p, ok)
+	}
+	if p, ok := tree.Delete(point{0, 0}); !ok || p.x != 0 {
+		t.Errorf("expected to delete 0, got %v (%v)", p, ok)
+	}
+	if _, ok := tree.Delete(point{0, 0}); ok {
+		t.Errorf("deleted non-existent item")
+	}
+	if p, ok := tree.Min(); !ok || p.x != 1 {
+		t.Errorf("expected min 1, got %v (%v)", p, ok)
+	}
+	prev := float32(0)
+	tree.Ascend(func(p point) bool {
+		if p.x <= prev {
+			t.Fatalf("bad order: %v after %v", p.x, prev)
+		}
+		prev = p.x
+		return true
+	})
+	if tree.Len() != 99 {
+		t.Errorf("expected len 99, got %d", tree.Len())
+	}
+}
@@ -7,6 +7,8 @@
 
 package llrb
 
+import "fmt"
+
 type Int int
 
 type Float32 float32
@@ -16,17 +18,42 @@ type String string
 // Use in LLRB.New constructor to make a tree that assumes integer items,
 // and sorts from smallest to largest.
 func NaturalSortLessInt(a, b interface{}) bool {
-	return a.(Int) < b.(Int)
+	x, okA := a.(Int)
+	y, okB := b.(Int)
+	if !okA || !okB {
+		panic(typeMismatch(Int(0), a, b))
+	}
+	return x < y
 }
 
 // Use in LLRB.New constructor to make a tree that assumes float32 items,
 // and sorts from smallest to largest.
 func NaturalSortLessFloat(a, b interface{}) bool {
-	return a.(Float32) < b.(Float32)
+	x, okA := a.(Float32)
+	y, okB := b.(Float32)
+	if !okA || !okB {
+		panic(typeMismatch(Float32(0), a, b))
+	}
+	return x < y
 }
 
 // Use in LLRB.New constructor to make a tree that assumes string items,
 // and sorts alphabetically from smallest to largest (e.g. "a" < "b" "c".
 func NaturalSortLessString(a, b interface{}) bool {
-	return a.(String) < b.(String)
+	x, okA := a.(String)
+	y, okB := b.(String)
+	if !okA || !okB {
+		panic(typeMismatch(String(""), a, b))
+	}
+	return x < y
+}
+
+// typeMismatch describes the item among a and b that is not of the same type
+// as want, such as an int inserted into a tree of Int.
+func typeMismatch(want, a, b interface{}) string {
+	bad := a
+	if fmt.Sprintf("%T", a) == fmt.Sprintf("%T", want) {
+		bad = b
+	}
+	return fmt.Sprintf("llrb: expected an item of type %T, got %T (%#v)", want, bad, bad)
 }
@@ -0,0 +1,32 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import "testing"
+
+func TestComparerTypeMismatch(t *testing.T) {
+	cases := []struct {
+		comp     Comparer
+		good     Item
+		bad      Item
+		expected string
+	}{
+		{NaturalSortLessInt, Int(1), 2, "llrb: expected an item of type llrb.Int, got int (2)"},
+		{NaturalSortLessFloat, Float32(1), 2.5, "llrb: expected an item of type llrb.Float32, got float64 (2.5)"},
+		{NaturalSortLessString, String("a"), "b", `llrb: expected an item of type llrb.String, got string ("b")`},
+	}
+	for _, c := range cases {
+		tree := New(c.comp)
+		tree.ReplaceOrInsert(c.good)
+		func() {
+			defer func() {
+				if r := recover(); r != c.expected {
+					t.Errorf("expected panic %q, got %v", c.expected, r)
+				}
+			}()
+			tree.ReplaceOrInsert(c.bad)
+		}()
+	}
+}
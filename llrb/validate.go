@@ -0,0 +1,58 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import "fmt"
+
+// Validate checks the invariants of the tree, and returns an error naming the
+// first one violated and the item of the offending node, or nil if they all
+// hold. It takes O(n) time and is meant for tests.
+func (t *LLRB) Validate() error {
+	if isRed(t.root) {
+		return fmt.Errorf("llrb: root %v is red", t.root.Item)
+	}
+	_, n, err := t.validate(t.root, Inf(-1), Inf(1))
+	if err != nil {
+		return err
+	}
+	if n != t.count {
+		return fmt.Errorf("llrb: tree has %d nodes, but Len is %d", n, t.count)
+	}
+	return nil
+}
+
+// validate checks the subtree at h, whose items must lie between lo and hi,
+// and returns its black height and number of nodes.
+func (t *LLRB) validate(h *Node, lo, hi Item) (black, n int, err error) {
+	if h == nil {
+		return 0, 0, nil
+	}
+	switch {
+	case less(t.comp, h.Item, lo) || less(t.comp, hi, h.Item):
+		return 0, 0, fmt.Errorf("llrb: node %v is out of order", h.Item)
+	case isRed(h.Right) && (t.mode == Mode23 || !isRed(h.Left)):
+		return 0, 0, fmt.Errorf("llrb: node %v has a red right link", h.Item)
+	case isRed(h) && (isRed(h.Left) || isRed(h.Right)):
+		return 0, 0, fmt.Errorf("llrb: node %v has two red links in a row", h.Item)
+	}
+	lb, ln, err := t.validate(h.Left, lo, h.Item)
+	if err != nil {
+		return 0, 0, err
+	}
+	rb, rn, err := t.validate(h.Right, h.Item, hi)
+	if err != nil {
+		return 0, 0, err
+	}
+	if lb != rb {
+		return 0, 0, fmt.Errorf("llrb: node %v is not black-balanced", h.Item)
+	}
+	if n = 1 + ln + rn; h.size != n {
+		return 0, 0, fmt.Errorf("llrb: node %v has size %d, expected %d", h.Item, h.size, n)
+	}
+	if h.Black {
+		lb++
+	}
+	return lb, n, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestValidateRandomOps(t *testing.T) {
+	trees := map[string]*LLRB{
+		"2-3":    New(NaturalSortLessInt),
+		"2-3-4":  NewWithMode(NaturalSortLessInt, Mode234),
+		"stable": NewStable(NaturalSortLessInt),
+	}
+	for name, tree := range trees {
+		r := rand.New(rand.NewSource(1))
+		for op := 0; op < 5000; op++ {
+			i := Int(r.Intn(200))
+			switch r.Intn(5) {
+			case 0, 1:
+				if tree.stable {
+					tree.InsertNoReplace(i)
+				} else {
+					tree.ReplaceOrInsert(i)
+				}
+			case 2:
+				tree.Delete(i)
+			case 3:
+				tree.DeleteMin()
+			case 4:
+				tree.DeleteMax()
+			}
+			if err := tree.Validate(); err != nil {
+				t.Fatalf("%s: op %d: %v", name, op, err)
+			}
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 10; i++ {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	tests := []struct {
+		corrupt func(root *Node)
+		want    string
+	}{
+		{func(root *Node) { root.Black = false }, "root 3 is red"},
+		{func(root *Node) { root.Item = Int(100) }, "out of order"},
+		{func(root *Node) { root.Right.Black = false }, "red right link"},
+		{func(root *Node) { root.Left.Left.Black = false }, "not black-balanced"},
+		{func(root *Node) { root.size++ }, "size"},
+	}
+	for _, test := range tests {
+		c := tree.Clone()
+		test.corrupt(c.Root())
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("expected an error containing %q, got %v", test.want, err)
+		}
+	}
+	if err := tree.Validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
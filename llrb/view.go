@@ -0,0 +1,52 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+// View is a read-only view of the elements of a tree that are greater or
+// equal to a lower bound and less than an upper bound. A View holds no items
+// of its own, so it always reflects the current contents of the tree.
+type View struct {
+	t      *LLRB
+	lo, hi Item
+}
+
+// Range returns a view of the elements greater or equal to lo and less than hi.
+func (t *LLRB) Range(lo, hi Item) *View {
+	return &View{t: t, lo: lo, hi: hi}
+}
+
+// Len returns the number of elements in the view, in O(log n) time.
+func (v *View) Len() int { return v.t.CountRange(v.lo, v.hi) }
+
+// Has returns true if the view contains an element whose order is the same as that of key.
+func (v *View) Has(key Item) bool {
+	return v.contains(key) && v.t.Has(key)
+}
+
+// Min returns the minimum element in the view, or nil if the view is empty.
+func (v *View) Min() Item {
+	if m := v.t.Ceiling(v.lo); m != nil && v.contains(m) {
+		return m
+	}
+	return nil
+}
+
+// Max returns the maximum element in the view, or nil if the view is empty.
+func (v *View) Max() Item {
+	if m := v.t.Predecessor(v.hi); m != nil && v.contains(m) {
+		return m
+	}
+	return nil
+}
+
+// Ascend will call iterator once for each element in the view, in ascending
+// order. It will stop whenever the iterator returns false.
+func (v *View) Ascend(iterator ItemIterator) {
+	v.t.AscendRange(v.lo, v.hi, iterator)
+}
+
+func (v *View) contains(i Item) bool {
+	return !less(v.t.comp, i, v.lo) && less(v.t.comp, i, v.hi)
+}
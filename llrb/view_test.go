@@ -0,0 +1,48 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	for i := 0; i < 100; i += 10 {
+		tree.ReplaceOrInsert(Int(i))
+	}
+	v := tree.Range(Int(15), Int(50))
+	if v.Len() != 3 || v.Min() != Int(20) || v.Max() != Int(40) {
+		t.Errorf("unexpected view len %d, min %v, max %v", v.Len(), v.Min(), v.Max())
+	}
+	if !v.Has(Int(30)) || v.Has(Int(50)) || v.Has(Int(10)) || v.Has(Int(25)) {
+		t.Errorf("unexpected view membership")
+	}
+	var ary []Item
+	v.Ascend(func(i Item) bool {
+		ary = append(ary, i)
+		return true
+	})
+	expected := []Item{Int(20), Int(30), Int(40)}
+	if !reflect.DeepEqual(ary, expected) {
+		t.Errorf("expected %v but got %v", expected, ary)
+	}
+
+	// The view is live.
+	tree.ReplaceOrInsert(Int(15))
+	tree.Delete(Int(40))
+	if v.Len() != 3 || v.Min() != Int(15) || v.Max() != Int(30) {
+		t.Errorf("view did not follow the tree: len %d, min %v, max %v", v.Len(), v.Min(), v.Max())
+	}
+
+	empty := tree.Range(Int(50), Int(15))
+	if empty.Len() != 0 || empty.Min() != nil || empty.Max() != nil {
+		t.Errorf("expected an empty view for inverted bounds")
+	}
+	if empty = tree.Range(Int(41), Int(49)); empty.Len() != 0 || empty.Min() != nil || empty.Max() != nil {
+		t.Errorf("expected an empty view between items")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+// TraversalOrder selects the order in which WalkNodes visits nodes.
+type TraversalOrder int
+
+const (
+	PreOrder  TraversalOrder = iota // A node before its children
+	InOrder                         // A node between its left and right children
+	PostOrder                       // A node after its children
+)
+
+// WalkNodes calls fn for each node of the tree in the given order, along with
+// the node's depth, the root being at depth 0. It will stop whenever fn
+// returns false. The nodes are exposed for inspection only; fn must not
+// modify them.
+func (t *LLRB) WalkNodes(order TraversalOrder, fn func(n *Node, depth int) bool) {
+	defer t.walk()()
+	walkNodes(t.root, 0, order, fn)
+}
+
+func walkNodes(h *Node, depth int, order TraversalOrder, fn func(*Node, int) bool) bool {
+	if h == nil {
+		return true
+	}
+	if order == PreOrder && !fn(h, depth) {
+		return false
+	}
+	if !walkNodes(h.Left, depth+1, order, fn) {
+		return false
+	}
+	if order == InOrder && !fn(h, depth) {
+		return false
+	}
+	if !walkNodes(h.Right, depth+1, order, fn) {
+		return false
+	}
+	if order == PostOrder && !fn(h, depth) {
+		return false
+	}
+	return true
+}
+
+// WalkAction tells Walk how to proceed after visiting a node.
+type WalkAction int
+
+const (
+	Continue  WalkAction = iota // Visit both children
+	SkipLeft                    // Skip the left subtree
+	SkipRight                   // Skip the right subtree
+	SkipBoth                    // Skip both subtrees
+	Stop                        // End the walk
+)
+
+// Walk calls fn for the nodes of the tree in pre-order, visiting the children
+// of each node as directed by the action that fn returns for it. Skipping a
+// subtree skips every item that sorts on that side of the node, which allows
+// bounded searches to prune the tree. As with WalkNodes, fn must not modify
+// the nodes.
+func (t *LLRB) Walk(fn func(n *Node) WalkAction) {
+	defer t.walk()()
+	walkPruned(t.root, fn)
+}
+
+func walkPruned(h *Node, fn func(*Node) WalkAction) bool {
+	if h == nil {
+		return true
+	}
+	action := fn(h)
+	if action == Stop {
+		return false
+	}
+	if action != SkipLeft && action != SkipBoth && !walkPruned(h.Left, fn) {
+		return false
+	}
+	if action != SkipRight && action != SkipBoth && !walkPruned(h.Right, fn) {
+		return false
+	}
+	return true
+}
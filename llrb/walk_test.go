@@ -0,0 +1,107 @@
+// Copyright 2010 Petar Maymounkov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package llrb
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWalkNodes(t *testing.T) {
+	// Builds the tree 2(1, 3), all black.
+	tree := New(NaturalSortLessInt)
+	tree.ReplaceOrInsert(Int(1))
+	tree.ReplaceOrInsert(Int(2))
+	tree.ReplaceOrInsert(Int(3))
+	tests := []struct {
+		order  TraversalOrder
+		items  []Item
+		depths []int
+	}{
+		{PreOrder, []Item{Int(2), Int(1), Int(3)}, []int{0, 1, 1}},
+		{InOrder, []Item{Int(1), Int(2), Int(3)}, []int{1, 0, 1}},
+		{PostOrder, []Item{Int(1), Int(3), Int(2)}, []int{1, 1, 0}},
+	}
+	for _, test := range tests {
+		var items []Item
+		var depths []int
+		tree.WalkNodes(test.order, func(n *Node, depth int) bool {
+			items = append(items, n.Item)
+			depths = append(depths, depth)
+			return true
+		})
+		if !reflect.DeepEqual(items, test.items) || !reflect.DeepEqual(depths, test.depths) {
+			t.Errorf("order %d: expected %v at %v, got %v at %v",
+				test.order, test.items, test.depths, items, depths)
+		}
+	}
+	k := 0
+	tree.WalkNodes(PostOrder, func(n *Node, depth int) bool {
+		k++
+		return false
+	})
+	if k != 1 {
+		t.Errorf("expected the walk to stop after one node, visited %d", k)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	tree := New(NaturalSortLessInt)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		tree.ReplaceOrInsert(Int(2 * i))
+	}
+	lo, hi := Int(301), Int(600)
+	var oracle []Item
+	for _, i := range tree.ToSlice() {
+		if i.(Int) >= lo && i.(Int) < hi {
+			oracle = append(oracle, i)
+		}
+	}
+
+	// Collect the items in [lo, hi), pruning the subtrees outside it.
+	var got []Item
+	visited := 0
+	tree.Walk(func(n *Node) WalkAction {
+		visited++
+		switch i := n.Item.(Int); {
+		case i < lo:
+			return SkipLeft
+		case i >= hi:
+			return SkipRight
+		}
+		got = append(got, n.Item)
+		return Continue
+	})
+	sort.Slice(got, func(a, b int) bool { return got[a].(Int) < got[b].(Int) })
+	if !reflect.DeepEqual(got, oracle) {
+		t.Errorf("expected %v, got %v", oracle, got)
+	}
+	if visited > len(oracle)+2*tree.Height() {
+		t.Errorf("visited %d nodes for %d items", visited, len(oracle))
+	}
+
+	visited = 0
+	tree.Walk(func(n *Node) WalkAction {
+		visited++
+		if visited == 3 {
+			return Stop
+		}
+		return Continue
+	})
+	if visited != 3 {
+		t.Errorf("expected the walk to stop after 3 nodes, visited %d", visited)
+	}
+	visited = 0
+	tree.Walk(func(n *Node) WalkAction {
+		visited++
+		return SkipBoth
+	})
+	if visited != 1 {
+		t.Errorf("expected only the root to be visited, visited %d", visited)
+	}
+}